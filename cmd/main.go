@@ -5,15 +5,21 @@ import (
 	"log"
 	"sync"
 
+	"github.com/bocharovatd/mitm-proxy/internal/config"
 	"github.com/bocharovatd/mitm-proxy/internal/pkg/db/mongo"
 	httpServer "github.com/bocharovatd/mitm-proxy/internal/server/http"
 	proxyServer "github.com/bocharovatd/mitm-proxy/internal/server/proxy"
 )
 
 func main() {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("error loading config: %v", err)
+	}
+
 	mongoClient, err := mongo.New()
 	if err != nil {
-		log.Fatalf("error creating mongo client", err)
+		log.Fatalf("error creating mongo client: %v", err)
 	}
 	log.Println("Mongo client created")
 	defer mongoClient.Disconnect(context.TODO())
@@ -23,10 +29,10 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		proxyServer := proxyServer.New(mongoClient)
+		proxyServer := proxyServer.New(mongoClient, cfg)
 		err := proxyServer.Run()
 		if err != nil {
-			log.Fatalf("Error starting MITM proxy:", err)
+			log.Fatalf("Error starting MITM proxy: %v", err)
 		}
 		log.Println("MITM Proxy stopped:", err)
 	}()
@@ -34,10 +40,10 @@ func main() {
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		httpServer := httpServer.New(mongoClient)
+		httpServer := httpServer.New(mongoClient, cfg)
 		err = httpServer.Run()
 		if err != nil {
-			log.Fatalf("failed ro run API web server: ", err)
+			log.Fatalf("failed ro run API web server: %v", err)
 		}
 		log.Println("API web server stopped:", err)
 	}()
@@ -0,0 +1,12 @@
+package auth
+
+import (
+	"net/http"
+)
+
+type Handlers interface {
+	LoginPage(w http.ResponseWriter, r *http.Request)
+	Login(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	Middleware(next http.Handler) http.Handler
+}
@@ -0,0 +1,271 @@
+package http
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
+	"html/template"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+
+	"github.com/bocharovatd/mitm-proxy/internal/auth"
+	authEntity "github.com/bocharovatd/mitm-proxy/internal/auth/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/websession"
+)
+
+const (
+	sessionCookieName = "mitm_session"
+	csrfCookieName    = "mitm_csrf"
+	loginPath         = "/login"
+	// multipartPeekSize — сколько байт multipart-тела читается в поисках CSRF-токена.
+	multipartPeekSize = 4 << 10
+)
+
+type AuthHandlers struct {
+	usecase auth.Usecase
+	tmpl    *template.Template
+}
+
+func NewAuthHandlers(authUC auth.Usecase) auth.Handlers {
+	tmpl := template.Must(template.ParseGlob("templates/*.html"))
+	return &AuthHandlers{
+		usecase: authUC,
+		tmpl:    tmpl,
+	}
+}
+
+func (handlers *AuthHandlers) LoginPage(w http.ResponseWriter, r *http.Request) {
+	handlers.renderLogin(w, r, "", http.StatusOK)
+}
+
+func (handlers *AuthHandlers) Login(w http.ResponseWriter, r *http.Request) {
+	user := r.PostFormValue("username")
+
+	session, err := handlers.usecase.Login(user, r.PostFormValue("password"))
+	if errors.Is(err, authEntity.ErrInvalidCredentials) {
+		log.Printf("Failed login for user %q from %s", user, r.RemoteAddr)
+		handlers.renderLogin(w, r, "Invalid username or password", http.StatusUnauthorized)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to log in: %v", err)
+		http.Error(w, "Login failed", http.StatusInternalServerError)
+		return
+	}
+
+	// Прежняя сессия заменяется новой, чтобы её идентификатор нельзя было подставить заранее
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := handlers.usecase.Logout(cookie.Value); err != nil {
+			log.Printf("Failed to delete previous session: %v", err)
+		}
+	}
+
+	setSessionCookie(w, session)
+	http.Redirect(w, r, "/requests", http.StatusSeeOther)
+}
+
+func (handlers *AuthHandlers) Logout(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(sessionCookieName); err == nil {
+		if err := handlers.usecase.Logout(cookie.Value); err != nil {
+			log.Printf("Failed to log out: %v", err)
+		}
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	http.Redirect(w, r, loginPath, http.StatusSeeOther)
+}
+
+// Middleware пропускает запросы с API-токеном или сессией вошедшего пользователя
+// и проверяет CSRF-токен в запросах, изменяющих состояние. Клиенты без сессии
+// (форма входа или вход не настроен) получают подписанный токен в cookie.
+func (handlers *AuthHandlers) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Заголовок Authorization браузер не подставляет сам, поэтому CSRF-токен здесь не нужен
+		if token, ok := bearerToken(r); ok {
+			if !handlers.usecase.CheckToken(token) {
+				http.Error(w, "Invalid API token", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		session := handlers.currentSession(r)
+		public := r.URL.Path == loginPath
+
+		if handlers.usecase.Enabled() && !public && session == nil {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				http.Redirect(w, r, loginPath, http.StatusSeeOther)
+				return
+			}
+			http.Error(w, "Authentication required", http.StatusUnauthorized)
+			return
+		}
+
+		var user, csrfToken string
+		if session != nil {
+			user, csrfToken = session.User, session.CSRFToken
+		} else {
+			var err error
+			csrfToken, err = handlers.anonymousCSRFToken(w, r)
+			if err != nil {
+				log.Printf("Failed to create CSRF token: %v", err)
+				http.Error(w, "Failed to create CSRF token", http.StatusInternalServerError)
+				return
+			}
+		}
+
+		if !isSafeMethod(r.Method) && !validCSRFToken(r, csrfToken) {
+			log.Printf("Rejected %s %s from %s: invalid CSRF token", r.Method, r.URL.Path, r.RemoteAddr)
+			http.Error(w, "Invalid CSRF token", http.StatusForbidden)
+			return
+		}
+
+		next.ServeHTTP(w, r.WithContext(websession.WithSession(r.Context(), user, csrfToken)))
+	})
+}
+
+// currentSession возвращает сессию вошедшего пользователя. Сессии без
+// пользователя от прежних версий не учитываются.
+func (handlers *AuthHandlers) currentSession(r *http.Request) *authEntity.Session {
+	cookie, err := r.Cookie(sessionCookieName)
+	if err != nil {
+		return nil
+	}
+
+	session, err := handlers.usecase.GetSession(cookie.Value)
+	if err != nil {
+		log.Printf("Failed to get session: %v", err)
+		return nil
+	}
+	if session == nil || session.User == "" {
+		return nil
+	}
+	return session
+}
+
+// anonymousCSRFToken возвращает токен из cookie клиента без сессии или выдаёт
+// новый, если cookie нет или подпись не сходится.
+func (handlers *AuthHandlers) anonymousCSRFToken(w http.ResponseWriter, r *http.Request) (string, error) {
+	if cookie, err := r.Cookie(csrfCookieName); err == nil && handlers.usecase.CheckCSRFToken(cookie.Value) {
+		return cookie.Value, nil
+	}
+
+	token, err := handlers.usecase.NewCSRFToken()
+	if err != nil {
+		return "", err
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+	return token, nil
+}
+
+func (handlers *AuthHandlers) renderLogin(w http.ResponseWriter, r *http.Request, message string, status int) {
+	data := struct {
+		Title     string
+		Error     string
+		CSRFToken string
+	}{
+		Title:     "Login",
+		Error:     message,
+		CSRFToken: websession.CSRFToken(r),
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := handlers.tmpl.ExecuteTemplate(w, "login.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+		return
+	}
+}
+
+func setSessionCookie(w http.ResponseWriter, session *authEntity.Session) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     sessionCookieName,
+		Value:    session.ID,
+		Path:     "/",
+		Expires:  session.ExpiresAt,
+		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
+	})
+}
+
+func bearerToken(r *http.Request) (string, bool) {
+	const prefix = "Bearer "
+	header := r.Header.Get("Authorization")
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", false
+	}
+	return header[len(prefix):], true
+}
+
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// validCSRFToken ищет токен в заголовке, в полях формы или в первой части
+// multipart-формы: остальное тело multipart разбирает сам обработчик со своим
+// ограничением размера.
+func validCSRFToken(r *http.Request, expected string) bool {
+	token := r.Header.Get(websession.CSRFHeader)
+	if token == "" {
+		mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		switch mediaType {
+		case "application/x-www-form-urlencoded":
+			token = r.PostFormValue(websession.CSRFField)
+		case "multipart/form-data":
+			token = multipartCSRFToken(r, params["boundary"])
+		}
+	}
+
+	if token == "" || expected == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(expected)) == 1
+}
+
+// multipartCSRFToken читает начало тела не больше multipartPeekSize байт и
+// берёт токен из первой части формы, после чего возвращает прочитанное в тело
+// запроса. Поле с токеном должно идти в форме первым.
+func multipartCSRFToken(r *http.Request, boundary string) string {
+	if boundary == "" {
+		return ""
+	}
+
+	peeked, err := io.ReadAll(io.LimitReader(r.Body, multipartPeekSize))
+	r.Body = readCloser{io.MultiReader(bytes.NewReader(peeked), r.Body), r.Body}
+	if err != nil {
+		return ""
+	}
+
+	part, err := multipart.NewReader(bytes.NewReader(peeked), boundary).NextPart()
+	if err != nil || part.FormName() != websession.CSRFField {
+		return ""
+	}
+	token, err := io.ReadAll(part)
+	if err != nil {
+		return ""
+	}
+	return string(token)
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,18 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+var ErrInvalidCredentials = errors.New("invalid username or password")
+
+// Session — сессия вошедшего пользователя веб-интерфейса. Клиенты без сессии
+// получают CSRF-токен в cookie, без записи в базу.
+type Session struct {
+	ID        string    `bson:"_id"`
+	User      string    `bson:"user,omitempty"`
+	CSRFToken string    `bson:"csrf_token"`
+	CreatedAt time.Time `bson:"created_at"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
@@ -0,0 +1,12 @@
+package auth
+
+import (
+	authEntity "github.com/bocharovatd/mitm-proxy/internal/auth/entity"
+)
+
+type Repository interface {
+	SaveSession(session *authEntity.Session) error
+	GetSession(id string) (*authEntity.Session, error)
+	DeleteSession(id string) error
+	Migrate() error
+}
@@ -0,0 +1,64 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/bocharovatd/mitm-proxy/internal/auth"
+	authEntity "github.com/bocharovatd/mitm-proxy/internal/auth/entity"
+)
+
+type AuthRepository struct {
+	mongoCollection *mongo.Collection
+}
+
+func NewAuthRepository(mongoClient *mongo.Client) auth.Repository {
+	collection := mongoClient.Database("MongoBD").Collection("sessions")
+	return &AuthRepository{mongoCollection: collection}
+}
+
+func (repository *AuthRepository) SaveSession(session *authEntity.Session) error {
+	if _, err := repository.mongoCollection.InsertOne(context.Background(), session); err != nil {
+		return fmt.Errorf("failed to save session: %v", err)
+	}
+	return nil
+}
+
+// GetSession возвращает nil без ошибки, если сессии нет.
+func (repository *AuthRepository) GetSession(id string) (*authEntity.Session, error) {
+	var session authEntity.Session
+	err := repository.mongoCollection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&session)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %v", err)
+	}
+	return &session, nil
+}
+
+func (repository *AuthRepository) DeleteSession(id string) error {
+	if _, err := repository.mongoCollection.DeleteOne(context.Background(), bson.M{"_id": id}); err != nil {
+		return fmt.Errorf("failed to delete session: %v", err)
+	}
+	return nil
+}
+
+// Migrate создаёт TTL-индекс: MongoDB сама удаляет истёкшие сессии.
+func (repository *AuthRepository) Migrate() error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "expires_at", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	}
+
+	if _, err := repository.mongoCollection.Indexes().CreateOne(context.Background(), index); err != nil {
+		return fmt.Errorf("failed to create session index: %w", err)
+	}
+
+	return nil
+}
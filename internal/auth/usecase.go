@@ -0,0 +1,15 @@
+package auth
+
+import (
+	authEntity "github.com/bocharovatd/mitm-proxy/internal/auth/entity"
+)
+
+type Usecase interface {
+	Enabled() bool
+	Login(user, password string) (*authEntity.Session, error)
+	GetSession(id string) (*authEntity.Session, error)
+	Logout(id string) error
+	CheckToken(token string) bool
+	NewCSRFToken() (string, error)
+	CheckCSRFToken(token string) bool
+}
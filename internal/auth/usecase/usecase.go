@@ -0,0 +1,144 @@
+package usecase
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/bocharovatd/mitm-proxy/internal/auth"
+	authEntity "github.com/bocharovatd/mitm-proxy/internal/auth/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/config"
+)
+
+type AuthUsecase struct {
+	repository auth.Repository
+	cfg        config.WebConfig
+	// dummyHash сравнивается с паролем неизвестного пользователя, чтобы время
+	// ответа не выдавало, существует ли имя
+	dummyHash []byte
+	// csrfKey подписывает CSRF-токены клиентов без сессии. Ключ создаётся при
+	// запуске, после перезапуска такие клиенты просто получают новый токен
+	csrfKey []byte
+}
+
+func NewAuthUsecase(repo auth.Repository, cfg config.WebConfig) auth.Usecase {
+	dummyHash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	csrfKey := make([]byte, 32)
+	rand.Read(csrfKey)
+	return &AuthUsecase{
+		repository: repo,
+		cfg:        cfg,
+		dummyHash:  dummyHash,
+		csrfKey:    csrfKey,
+	}
+}
+
+// Enabled сообщает, требуется ли вход: заданы пользователи или API-токен.
+func (usecase *AuthUsecase) Enabled() bool {
+	return len(usecase.cfg.Users) > 0 || usecase.cfg.APIToken != ""
+}
+
+func (usecase *AuthUsecase) Login(user, password string) (*authEntity.Session, error) {
+	hash, exists := usecase.cfg.Users[user]
+	if !exists {
+		bcrypt.CompareHashAndPassword(usecase.dummyHash, []byte(password))
+		return nil, authEntity.ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password)); err != nil {
+		return nil, authEntity.ErrInvalidCredentials
+	}
+
+	return usecase.newSession(user)
+}
+
+// NewCSRFToken выдаёт подписанный CSRF-токен для клиента без сессии. Токен
+// ничего не сохраняет на сервере: клиент хранит его в cookie и повторяет в форме.
+func (usecase *AuthUsecase) NewCSRFToken() (string, error) {
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	return nonce + "." + usecase.sign(nonce), nil
+}
+
+// CheckCSRFToken проверяет подпись токена, выданного NewCSRFToken.
+func (usecase *AuthUsecase) CheckCSRFToken(token string) bool {
+	nonce, signature, ok := strings.Cut(token, ".")
+	if !ok || nonce == "" {
+		return false
+	}
+	return hmac.Equal([]byte(signature), []byte(usecase.sign(nonce)))
+}
+
+// GetSession возвращает nil, если сессии нет или она истекла.
+func (usecase *AuthUsecase) GetSession(id string) (*authEntity.Session, error) {
+	session, err := usecase.repository.GetSession(id)
+	if err != nil {
+		return nil, err
+	}
+	// MongoDB удаляет истёкшие сессии с задержкой
+	if session == nil || time.Now().After(session.ExpiresAt) {
+		return nil, nil
+	}
+	return session, nil
+}
+
+func (usecase *AuthUsecase) Logout(id string) error {
+	return usecase.repository.DeleteSession(id)
+}
+
+func (usecase *AuthUsecase) CheckToken(token string) bool {
+	if usecase.cfg.APIToken == "" {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(usecase.cfg.APIToken)) == 1
+}
+
+func (usecase *AuthUsecase) newSession(user string) (*authEntity.Session, error) {
+	id, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	csrfToken, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	session := &authEntity.Session{
+		ID:        id,
+		User:      user,
+		CSRFToken: csrfToken,
+		CreatedAt: now,
+		ExpiresAt: now.Add(usecase.cfg.SessionTTL),
+	}
+
+	if err := usecase.repository.SaveSession(session); err != nil {
+		return nil, err
+	}
+
+	return session, nil
+}
+
+func (usecase *AuthUsecase) sign(value string) string {
+	mac := hmac.New(sha256.New, usecase.csrfKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func randomToken() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
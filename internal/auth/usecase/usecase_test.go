@@ -0,0 +1,151 @@
+package usecase
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/bocharovatd/mitm-proxy/internal/auth"
+	authEntity "github.com/bocharovatd/mitm-proxy/internal/auth/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/config"
+)
+
+// sessionRepository хранит сессии в памяти вместо MongoDB.
+type sessionRepository struct {
+	auth.Repository
+	sessions map[string]*authEntity.Session
+}
+
+func (r *sessionRepository) SaveSession(session *authEntity.Session) error {
+	r.sessions[session.ID] = session
+	return nil
+}
+
+func (r *sessionRepository) GetSession(id string) (*authEntity.Session, error) {
+	return r.sessions[id], nil
+}
+
+func TestCSRFToken(t *testing.T) {
+	usecase := NewAuthUsecase(nil, config.WebConfig{}).(*AuthUsecase)
+	other := NewAuthUsecase(nil, config.WebConfig{}).(*AuthUsecase)
+
+	token, err := usecase.NewCSRFToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+	nonce, signature, _ := strings.Cut(token, ".")
+	// flip заменяет первый символ строки другой шестнадцатеричной цифрой
+	flip := func(value string) string {
+		if value[0] == '0' {
+			return "1" + value[1:]
+		}
+		return "0" + value[1:]
+	}
+	foreign, err := other.NewCSRFToken()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		token string
+		want  bool
+	}{
+		{name: "issued token", token: token, want: true},
+		{name: "changed nonce", token: flip(nonce) + "." + signature},
+		{name: "changed signature", token: nonce + "." + flip(signature)},
+		{name: "signed with another key", token: foreign},
+		{name: "nonce without signature", token: nonce},
+		{name: "empty nonce", token: "." + usecase.sign("")},
+		{name: "empty token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := usecase.CheckCSRFToken(tt.token); got != tt.want {
+				t.Errorf("CheckCSRFToken(%q) = %v, want %v", tt.token, got, tt.want)
+			}
+		})
+	}
+
+	if second, _ := usecase.NewCSRFToken(); second == token {
+		t.Error("NewCSRFToken returned the same token twice")
+	}
+}
+
+func TestLogin(t *testing.T) {
+	hash, err := bcrypt.GenerateFromPassword([]byte("secret"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.WebConfig{Users: map[string]string{"alice": string(hash)}, SessionTTL: time.Hour}
+
+	tests := []struct {
+		name     string
+		user     string
+		password string
+		wantErr  error
+	}{
+		{name: "valid credentials", user: "alice", password: "secret"},
+		{name: "wrong password", user: "alice", password: "wrong", wantErr: authEntity.ErrInvalidCredentials},
+		{name: "unknown user", user: "bob", password: "secret", wantErr: authEntity.ErrInvalidCredentials},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &sessionRepository{sessions: map[string]*authEntity.Session{}}
+			usecase := NewAuthUsecase(repository, cfg)
+
+			session, err := usecase.Login(tt.user, tt.password)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				return
+			}
+
+			if session.User != tt.user || session.CSRFToken == "" || session.CSRFToken == session.ID {
+				t.Errorf("session = %+v", session)
+			}
+			if stored, _ := usecase.GetSession(session.ID); stored != session {
+				t.Errorf("session was not stored")
+			}
+		})
+	}
+}
+
+func TestGetSessionExpired(t *testing.T) {
+	repository := &sessionRepository{sessions: map[string]*authEntity.Session{
+		"expired": {ID: "expired", ExpiresAt: time.Now().Add(-time.Minute)},
+	}}
+	usecase := NewAuthUsecase(repository, config.WebConfig{})
+
+	if session, err := usecase.GetSession("expired"); session != nil || err != nil {
+		t.Errorf("GetSession = %+v, %v, want nil, nil", session, err)
+	}
+}
+
+func TestCheckToken(t *testing.T) {
+	tests := []struct {
+		name     string
+		apiToken string
+		token    string
+		want     bool
+	}{
+		{name: "matching token", apiToken: "token", token: "token", want: true},
+		{name: "other token", apiToken: "token", token: "other"},
+		{name: "no token configured", token: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usecase := NewAuthUsecase(nil, config.WebConfig{APIToken: tt.apiToken})
+			if got := usecase.CheckToken(tt.token); got != tt.want {
+				t.Errorf("CheckToken(%q) = %v, want %v", tt.token, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,419 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type Config struct {
+	Proxy       ProxyConfig
+	Certificate CertificateConfig
+	Upstream    UpstreamConfig
+	WebSocket   WebSocketConfig
+	Web         WebConfig
+}
+
+type ProxyConfig struct {
+	ClientIdleTimeout time.Duration
+	// BodyCaptureLimit — сколько байт тела запроса и ответа сохраняется в историю.
+	BodyCaptureLimit int64
+	// StreamRecordLimit — сколько событий SSE или сообщений WebSocket сохраняется в одну запись.
+	StreamRecordLimit int
+	// HTTP2 включает согласование h2 с клиентами на перехваченном TLS-соединении.
+	HTTP2 bool
+	// Passthrough — хосты (или маски *.example.com), TLS с которыми не расшифровывается.
+	Passthrough []string
+	// PassthroughAfterFailures — после скольких отказов клиентов от сертификата прокси
+	// хост переключается на передачу без расшифровки; 0 — не переключать.
+	PassthroughAfterFailures int
+	SOCKS                    SOCKSConfig
+	// TransparentAddress — адрес входа для соединений, перенаправленных iptables; пусто — выключен.
+	TransparentAddress string
+	Reverse            ReverseConfig
+	// Users — пользователи и пароли для Proxy-Authorization; пусто — аутентификация не требуется.
+	Users map[string]string
+	// Allow и Deny — подсети клиентов. Deny проверяется первым; непустой Allow пропускает только свои подсети.
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+// ReverseConfig — вход обратного прокси перед одним целевым сервером; пустой Address отключает его.
+type ReverseConfig struct {
+	Address string
+	// Upstream — адрес целевого сервера: http(s)://host[:port].
+	Upstream *url.URL
+	// Hostname — имя в сертификате, с которым прокси принимает TLS; пусто — клиенты подключаются по HTTP.
+	Hostname string
+}
+
+// SOCKSConfig — дополнительный вход SOCKS5; пустой Address отключает его.
+type SOCKSConfig struct {
+	Address string
+	// Username и Password — пользователь SOCKS5 в дополнение к Users; аутентификация
+	// требуется, если задан Username или Users.
+	Username string
+	Password string
+}
+
+type CertificateConfig struct {
+	CACertPath string
+	CAKeyPath  string
+	Validity   time.Duration
+	CacheSize  int
+	// KeyAlgorithm: rsa2048, rsa4096, ecdsa-p256, ecdsa-p384 или ed25519.
+	KeyAlgorithm string
+	// Wildcard включает выпуск сертификатов *.example.com вместо одного на каждый хост.
+	Wildcard bool
+}
+
+type UpstreamConfig struct {
+	MaxIdleConns        int
+	MaxIdleConnsPerHost int
+	// MaxConnsPerHost: 0 — без ограничения.
+	MaxConnsPerHost int
+	IdleConnTimeout time.Duration
+	// Proxy — вышестоящий прокси: http://[user:pass@]host:port или socks5://[user:pass@]host:port.
+	Proxy string
+	// ProxyBypass — хосты (или маски *.example.com), к которым нужно подключаться напрямую.
+	ProxyBypass []string
+	// TLSRules — настройки TLS для отдельных целевых хостов; применяется первое совпавшее правило.
+	TLSRules []UpstreamTLSRule
+}
+
+// UpstreamTLSRule — настройки TLS соединений с хостами Hosts (точные имена или маски *.example.com).
+type UpstreamTLSRule struct {
+	Hosts              []string `json:"hosts"`
+	InsecureSkipVerify bool     `json:"insecure_skip_verify"`
+	// CAFile — PEM-файл с корневыми сертификатами вместо системных.
+	CAFile string `json:"ca_file"`
+	// ClientCertFile и ClientKeyFile — клиентский сертификат для mTLS.
+	ClientCertFile string `json:"client_cert_file"`
+	ClientKeyFile  string `json:"client_key_file"`
+	// MinVersion и MaxVersion: "1.0", "1.1", "1.2" или "1.3".
+	MinVersion string `json:"min_version"`
+	MaxVersion string `json:"max_version"`
+	// CipherSuites — имена наборов шифров, например TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 (только до TLS 1.2).
+	CipherSuites []string `json:"cipher_suites"`
+}
+
+type WebSocketConfig struct {
+	// ReplayTimeout — сколько ждать ответов сервера после отправки сообщений при повторе и сканировании.
+	ReplayTimeout time.Duration
+}
+
+// WebConfig — доступ к веб-интерфейсу и API. Без пользователей и токена вход не требуется.
+type WebConfig struct {
+	// Users — пользователи и их пароли в виде хэшей bcrypt.
+	Users map[string]string
+	// APIToken — статический токен для заголовка Authorization: Bearer.
+	APIToken   string
+	SessionTTL time.Duration
+}
+
+func Load() (*Config, error) {
+	clientIdleTimeout, err := getDuration("MITM_CLIENT_IDLE_TIMEOUT", 60*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	bodyCaptureLimit, err := getInt("MITM_BODY_CAPTURE_LIMIT", 1<<20)
+	if err != nil {
+		return nil, err
+	}
+
+	streamRecordLimit, err := getInt("MITM_STREAM_RECORD_LIMIT", 1000)
+	if err != nil {
+		return nil, err
+	}
+
+	enableHTTP2, err := getBool("MITM_HTTP2", true)
+	if err != nil {
+		return nil, err
+	}
+
+	passthroughAfterFailures, err := getInt("MITM_TLS_PASSTHROUGH_AFTER_FAILURES", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	validity, err := getDuration("MITM_CERT_VALIDITY", 365*24*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	cacheSize, err := getInt("MITM_CERT_CACHE_SIZE", 1024)
+	if err != nil {
+		return nil, err
+	}
+
+	wildcard, err := getBool("MITM_CERT_WILDCARD", false)
+	if err != nil {
+		return nil, err
+	}
+
+	maxIdleConns, err := getInt("MITM_UPSTREAM_MAX_IDLE_CONNS", 100)
+	if err != nil {
+		return nil, err
+	}
+
+	maxIdleConnsPerHost, err := getInt("MITM_UPSTREAM_MAX_IDLE_CONNS_PER_HOST", 10)
+	if err != nil {
+		return nil, err
+	}
+
+	maxConnsPerHost, err := getInt("MITM_UPSTREAM_MAX_CONNS_PER_HOST", 0)
+	if err != nil {
+		return nil, err
+	}
+
+	idleConnTimeout, err := getDuration("MITM_UPSTREAM_IDLE_TIMEOUT", 90*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	tlsRules, err := loadTLSRules(getString("MITM_UPSTREAM_TLS_RULES", ""))
+	if err != nil {
+		return nil, err
+	}
+
+	reverse, err := loadReverseConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := getUsers("MITM_PROXY_USERS")
+	if err != nil {
+		return nil, err
+	}
+
+	allow, err := getPrefixes("MITM_PROXY_ALLOW")
+	if err != nil {
+		return nil, err
+	}
+
+	deny, err := getPrefixes("MITM_PROXY_DENY")
+	if err != nil {
+		return nil, err
+	}
+
+	replayTimeout, err := getDuration("MITM_WS_REPLAY_TIMEOUT", 2*time.Second)
+	if err != nil {
+		return nil, err
+	}
+
+	webUsers, err := getUsers("MITM_WEB_USERS")
+	if err != nil {
+		return nil, err
+	}
+	for user, hash := range webUsers {
+		if _, err := bcrypt.Cost([]byte(hash)); err != nil {
+			return nil, fmt.Errorf("invalid bcrypt hash for web user %s: %v", user, err)
+		}
+	}
+
+	sessionTTL, err := getDuration("MITM_WEB_SESSION_TTL", 12*time.Hour)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Config{
+		Proxy: ProxyConfig{
+			ClientIdleTimeout:        clientIdleTimeout,
+			BodyCaptureLimit:         int64(bodyCaptureLimit),
+			StreamRecordLimit:        streamRecordLimit,
+			HTTP2:                    enableHTTP2,
+			Passthrough:              getList("MITM_TLS_PASSTHROUGH"),
+			PassthroughAfterFailures: passthroughAfterFailures,
+			SOCKS: SOCKSConfig{
+				Address:  getString("MITM_SOCKS_ADDR", ""),
+				Username: getString("MITM_SOCKS_USERNAME", ""),
+				Password: getString("MITM_SOCKS_PASSWORD", ""),
+			},
+			TransparentAddress: getString("MITM_TRANSPARENT_ADDR", ""),
+			Reverse:            reverse,
+			Users:              users,
+			Allow:              allow,
+			Deny:               deny,
+		},
+		Certificate: CertificateConfig{
+			CACertPath:   getString("MITM_CA_CERT", "certs/ca.crt"),
+			CAKeyPath:    getString("MITM_CA_KEY", "certs/ca.key"),
+			Validity:     validity,
+			CacheSize:    cacheSize,
+			KeyAlgorithm: getString("MITM_CERT_KEY_ALGORITHM", "rsa2048"),
+			Wildcard:     wildcard,
+		},
+		Upstream: UpstreamConfig{
+			MaxIdleConns:        maxIdleConns,
+			MaxIdleConnsPerHost: maxIdleConnsPerHost,
+			MaxConnsPerHost:     maxConnsPerHost,
+			IdleConnTimeout:     idleConnTimeout,
+			Proxy:               getString("MITM_UPSTREAM_PROXY", ""),
+			ProxyBypass:         getList("MITM_UPSTREAM_PROXY_BYPASS"),
+			TLSRules:            tlsRules,
+		},
+		WebSocket: WebSocketConfig{
+			ReplayTimeout: replayTimeout,
+		},
+		Web: WebConfig{
+			Users:      webUsers,
+			APIToken:   getString("MITM_WEB_API_TOKEN", ""),
+			SessionTTL: sessionTTL,
+		},
+	}, nil
+}
+
+// loadTLSRules читает правила TLS для целевых хостов из JSON-файла.
+func loadTLSRules(path string) ([]UpstreamTLSRule, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upstream TLS rules: %v", err)
+	}
+
+	var rules []UpstreamTLSRule
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("failed to parse upstream TLS rules: %v", err)
+	}
+
+	for i, rule := range rules {
+		if len(rule.Hosts) == 0 {
+			return nil, fmt.Errorf("upstream TLS rule %d has no hosts", i+1)
+		}
+	}
+
+	return rules, nil
+}
+
+func loadReverseConfig() (ReverseConfig, error) {
+	reverse := ReverseConfig{
+		Address:  getString("MITM_REVERSE_ADDR", ""),
+		Hostname: getString("MITM_REVERSE_HOSTNAME", ""),
+	}
+
+	raw := getString("MITM_REVERSE_UPSTREAM", "")
+	if raw == "" {
+		if reverse.Address != "" {
+			return ReverseConfig{}, fmt.Errorf("MITM_REVERSE_UPSTREAM is required when MITM_REVERSE_ADDR is set")
+		}
+		return reverse, nil
+	}
+
+	upstream, err := url.Parse(raw)
+	if err != nil {
+		return ReverseConfig{}, fmt.Errorf("invalid value for MITM_REVERSE_UPSTREAM: %v", err)
+	}
+	if (upstream.Scheme != "http" && upstream.Scheme != "https") || upstream.Host == "" {
+		return ReverseConfig{}, fmt.Errorf("invalid value for MITM_REVERSE_UPSTREAM: expected http(s)://host[:port]")
+	}
+	if strings.Trim(upstream.Path, "/") != "" || upstream.RawQuery != "" {
+		return ReverseConfig{}, fmt.Errorf("invalid value for MITM_REVERSE_UPSTREAM: path and query are not supported")
+	}
+
+	reverse.Upstream = upstream
+	return reverse, nil
+}
+
+func getString(key, def string) string {
+	if value, ok := os.LookupEnv(key); ok && value != "" {
+		return value
+	}
+	return def
+}
+
+// getList разбирает список через запятую, пустые элементы пропускаются.
+func getList(key string) []string {
+	var result []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			result = append(result, item)
+		}
+	}
+	return result
+}
+
+// getUsers разбирает список user:password через запятую.
+func getUsers(key string) (map[string]string, error) {
+	users := make(map[string]string)
+	for _, item := range getList(key) {
+		user, password, ok := strings.Cut(item, ":")
+		if !ok || user == "" {
+			return nil, fmt.Errorf("invalid value for %s: expected user:password", key)
+		}
+		users[user] = password
+	}
+	return users, nil
+}
+
+// getPrefixes разбирает список подсетей через запятую; отдельный адрес считается подсетью из одного адреса.
+func getPrefixes(key string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, item := range getList(key) {
+		if !strings.Contains(item, "/") {
+			addr, err := netip.ParseAddr(item)
+			if err != nil {
+				return nil, fmt.Errorf("invalid value for %s: %v", key, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr, addr.BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(item)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %v", key, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	return prefixes, nil
+}
+
+// getInt разбирает неотрицательное целое: все числовые параметры — размеры и лимиты.
+func getInt(key string, def int) (int, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %v", key, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid value for %s: must not be negative", key)
+	}
+	return n, nil
+}
+
+func getBool(key string, def bool) (bool, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def, nil
+	}
+	b, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid value for %s: %v", key, err)
+	}
+	return b, nil
+}
+
+func getDuration(key string, def time.Duration) (time.Duration, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok || value == "" {
+		return def, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value for %s: %v", key, err)
+	}
+	return d, nil
+}
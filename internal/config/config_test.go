@@ -0,0 +1,28 @@
+package config
+
+import "testing"
+
+func TestGetInt(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    int
+		wantErr bool
+	}{
+		{name: "default", value: "", want: 7},
+		{name: "zero", value: "0", want: 0},
+		{name: "positive", value: "1048576", want: 1048576},
+		{name: "negative", value: "-1", wantErr: true},
+		{name: "not a number", value: "1MB", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MITM_TEST_INT", tt.value)
+			got, err := getInt("MITM_TEST_INT", 7)
+			if (err != nil) != tt.wantErr || got != tt.want {
+				t.Errorf("getInt = %d, %v, want %d, error %v", got, err, tt.want, tt.wantErr)
+			}
+		})
+	}
+}
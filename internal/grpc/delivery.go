@@ -0,0 +1,11 @@
+package grpc
+
+import (
+	"net/http"
+)
+
+type Handlers interface {
+	GetDescriptors(w http.ResponseWriter, r *http.Request)
+	AddDescriptor(w http.ResponseWriter, r *http.Request)
+	DeleteDescriptor(w http.ResponseWriter, r *http.Request)
+}
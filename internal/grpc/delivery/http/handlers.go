@@ -0,0 +1,94 @@
+package http
+
+import (
+	"html/template"
+	"io"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/bocharovatd/mitm-proxy/internal/grpc"
+	grpcEntity "github.com/bocharovatd/mitm-proxy/internal/grpc/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/websession"
+)
+
+// maxDescriptorSize ограничивает размер загружаемого набора дескрипторов.
+const maxDescriptorSize = 16 << 20
+
+type GRPCHandlers struct {
+	usecase grpc.Usecase
+	tmpl    *template.Template
+}
+
+func NewGRPCHandlers(grpcUC grpc.Usecase) grpc.Handlers {
+	tmpl := template.Must(template.ParseGlob("templates/*.html"))
+	return &GRPCHandlers{
+		usecase: grpcUC,
+		tmpl:    tmpl,
+	}
+}
+
+func (handlers *GRPCHandlers) GetDescriptors(w http.ResponseWriter, r *http.Request) {
+	descriptors, err := handlers.usecase.GetDescriptors()
+	if err != nil {
+		log.Printf("Failed to get proto descriptors: %v", err)
+		return
+	}
+
+	data := struct {
+		Title       string
+		Descriptors []*grpcEntity.Descriptor
+		CSRFToken   string
+	}{
+		Title:       "Proto Descriptors",
+		Descriptors: descriptors,
+		CSRFToken:   websession.CSRFToken(r),
+	}
+
+	if err := handlers.tmpl.ExecuteTemplate(w, "grpc_descriptors.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+		return
+	}
+}
+
+func (handlers *GRPCHandlers) AddDescriptor(w http.ResponseWriter, r *http.Request) {
+	r.Body = http.MaxBytesReader(w, r.Body, maxDescriptorSize)
+
+	file, header, err := r.FormFile("descriptor")
+	if err != nil {
+		http.Error(w, "Invalid descriptor upload", http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, "Invalid descriptor upload", http.StatusBadRequest)
+		return
+	}
+
+	name := r.FormValue("name")
+	if name == "" {
+		name = header.Filename
+	}
+
+	if _, err := handlers.usecase.AddDescriptor(name, data); err != nil {
+		log.Printf("Failed to add proto descriptor: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/grpc/descriptors", http.StatusSeeOther)
+}
+
+func (handlers *GRPCHandlers) DeleteDescriptor(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["descriptorID"]
+
+	if err := handlers.usecase.DeleteDescriptor(id); err != nil {
+		log.Printf("Failed to delete proto descriptor: %v", err)
+	}
+
+	http.Redirect(w, r, "/grpc/descriptors", http.StatusSeeOther)
+}
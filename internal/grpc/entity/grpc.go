@@ -0,0 +1,38 @@
+package entity
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Descriptor — загруженный набор дескрипторов (FileDescriptorSet), по которому
+// декодируются сообщения gRPC.
+type Descriptor struct {
+	ID         primitive.ObjectID `bson:"_id,omitempty"`
+	Name       string             `bson:"name"`
+	Data       []byte             `bson:"data"`
+	Services   []string           `bson:"services"`
+	UploadedAt time.Time          `bson:"uploaded_at"`
+}
+
+// Call — декодированный вызов gRPC из сохранённого запроса.
+type Call struct {
+	Service       string
+	Method        string
+	Status        string
+	StatusMessage string
+	Requests      []Message
+	Responses     []Message
+	// Error — ошибка разбора тела, например при обрезанном теле
+	RequestError  string
+	ResponseError string
+}
+
+// Message — одно сообщение вызова в виде JSON.
+type Message struct {
+	JSON string
+	// Schema — сообщение декодировано по загруженному дескриптору, а не по wire-формату
+	Schema     bool
+	Compressed bool
+}
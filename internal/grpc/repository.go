@@ -0,0 +1,11 @@
+package grpc
+
+import (
+	grpcEntity "github.com/bocharovatd/mitm-proxy/internal/grpc/entity"
+)
+
+type Repository interface {
+	SaveDescriptor(descriptor *grpcEntity.Descriptor) (string, error)
+	GetDescriptors() ([]*grpcEntity.Descriptor, error)
+	DeleteDescriptor(id string) error
+}
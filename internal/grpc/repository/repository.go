@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/bocharovatd/mitm-proxy/internal/grpc"
+	grpcEntity "github.com/bocharovatd/mitm-proxy/internal/grpc/entity"
+)
+
+type GRPCRepository struct {
+	mongoCollection *mongo.Collection
+}
+
+func NewGRPCRepository(mongoClient *mongo.Client) grpc.Repository {
+	collection := mongoClient.Database("MongoBD").Collection("proto_descriptors")
+	return &GRPCRepository{mongoCollection: collection}
+}
+
+func (repository *GRPCRepository) SaveDescriptor(descriptor *grpcEntity.Descriptor) (string, error) {
+	result, err := repository.mongoCollection.InsertOne(context.Background(), descriptor)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert descriptor: %v", err)
+	}
+
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		return oid.Hex(), nil
+	}
+
+	return "", fmt.Errorf("failed to get inserted ID")
+}
+
+func (repository *GRPCRepository) GetDescriptors() ([]*grpcEntity.Descriptor, error) {
+	var descriptors []*grpcEntity.Descriptor
+
+	cursor, err := repository.mongoCollection.Find(context.Background(), bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get descriptors: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var descriptor grpcEntity.Descriptor
+		if err := cursor.Decode(&descriptor); err != nil {
+			return nil, fmt.Errorf("failed to decode descriptor: %v", err)
+		}
+		descriptors = append(descriptors, &descriptor)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error while getting descriptors: %v", err)
+	}
+
+	return descriptors, nil
+}
+
+func (repository *GRPCRepository) DeleteDescriptor(id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to convert ID to ObjectID: %v", err)
+	}
+
+	if _, err := repository.mongoCollection.DeleteOne(context.Background(), bson.M{"_id": objectID}); err != nil {
+		return fmt.Errorf("failed to delete descriptor: %v", err)
+	}
+
+	return nil
+}
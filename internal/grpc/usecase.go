@@ -0,0 +1,13 @@
+package grpc
+
+import (
+	grpcEntity "github.com/bocharovatd/mitm-proxy/internal/grpc/entity"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+)
+
+type Usecase interface {
+	AddDescriptor(name string, data []byte) (string, error)
+	GetDescriptors() ([]*grpcEntity.Descriptor, error)
+	DeleteDescriptor(id string) error
+	Decode(record *requestEntity.RequestRecord) (*grpcEntity.Call, error)
+}
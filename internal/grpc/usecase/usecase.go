@@ -0,0 +1,214 @@
+package usecase
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protodesc"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/reflect/protoregistry"
+	"google.golang.org/protobuf/types/descriptorpb"
+
+	"github.com/bocharovatd/mitm-proxy/internal/grpc"
+	grpcEntity "github.com/bocharovatd/mitm-proxy/internal/grpc/entity"
+	grpcframe "github.com/bocharovatd/mitm-proxy/internal/pkg/grpc"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+)
+
+type GRPCUsecase struct {
+	repository grpc.Repository
+	// bodyCaptureLimit ограничивает распакованное сообщение так же, как сохранённое тело
+	bodyCaptureLimit int64
+}
+
+func NewGRPCUsecase(repo grpc.Repository, bodyCaptureLimit int64) grpc.Usecase {
+	return &GRPCUsecase{
+		repository:       repo,
+		bodyCaptureLimit: bodyCaptureLimit,
+	}
+}
+
+// AddDescriptor сохраняет FileDescriptorSet в бинарном виде
+// (protoc --include_imports --descriptor_set_out=...).
+func (usecase *GRPCUsecase) AddDescriptor(name string, data []byte) (string, error) {
+	files, err := parseDescriptorSet(data)
+	if err != nil {
+		return "", fmt.Errorf("invalid descriptor set: %v", err)
+	}
+
+	var services []string
+	files.RangeFiles(func(file protoreflect.FileDescriptor) bool {
+		for i := 0; i < file.Services().Len(); i++ {
+			services = append(services, string(file.Services().Get(i).FullName()))
+		}
+		return true
+	})
+
+	descriptor := &grpcEntity.Descriptor{
+		Name:       name,
+		Data:       data,
+		Services:   services,
+		UploadedAt: time.Now(),
+	}
+
+	id, err := usecase.repository.SaveDescriptor(descriptor)
+	if err != nil {
+		return "", fmt.Errorf("failed to save descriptor: %v", err)
+	}
+	return id, nil
+}
+
+func (usecase *GRPCUsecase) GetDescriptors() ([]*grpcEntity.Descriptor, error) {
+	descriptors, err := usecase.repository.GetDescriptors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get descriptors: %v", err)
+	}
+	return descriptors, nil
+}
+
+func (usecase *GRPCUsecase) DeleteDescriptor(id string) error {
+	if err := usecase.repository.DeleteDescriptor(id); err != nil {
+		return fmt.Errorf("failed to delete descriptor %s: %v", id, err)
+	}
+	return nil
+}
+
+// Decode разбирает тела запроса и ответа gRPC-вызова на сообщения. Если для метода
+// загружен дескриптор, сообщения декодируются по нему, иначе — по wire-формату.
+// Для запросов, не относящихся к gRPC, возвращается nil.
+func (usecase *GRPCUsecase) Decode(record *requestEntity.RequestRecord) (*grpcEntity.Call, error) {
+	if !grpcframe.IsGRPC(record.Request.Headers["Content-Type"]) {
+		return nil, nil
+	}
+
+	call := &grpcEntity.Call{}
+	if i := strings.LastIndex(record.Request.Path, "/"); i > 0 {
+		call.Service = strings.TrimPrefix(record.Request.Path[:i], "/")
+		call.Method = record.Request.Path[i+1:]
+	}
+
+	method, err := usecase.findMethod(call.Service, call.Method)
+	if err != nil {
+		return nil, fmt.Errorf("failed to find method descriptor: %v", err)
+	}
+
+	var input, output protoreflect.MessageDescriptor
+	if method != nil {
+		input, output = method.Input(), method.Output()
+	}
+
+	call.Requests, _, err = usecase.decodeMessages(record.Request.RawBody, record.Request.Headers["Grpc-Encoding"], input)
+	if err != nil {
+		call.RequestError = err.Error()
+	}
+
+	var trailers map[string]string
+	call.Responses, trailers, err = usecase.decodeMessages(record.Response.Body, record.Response.Headers["Grpc-Encoding"], output)
+	if err != nil {
+		call.ResponseError = err.Error()
+	}
+
+	// gRPC-Web передаёт статус кадром трейлеров в теле, а ответ без тела
+	// (trailers-only) — в заголовках
+	for _, source := range []map[string]string{record.Response.Trailers, trailers, record.Response.Headers} {
+		if status := source["Grpc-Status"]; status != "" {
+			call.Status = status
+			call.StatusMessage = source["Grpc-Message"]
+			break
+		}
+	}
+	if message, err := url.PathUnescape(call.StatusMessage); err == nil {
+		call.StatusMessage = message
+	}
+
+	return call, nil
+}
+
+// findMethod ищет метод среди загруженных дескрипторов. Каждый набор разбирается
+// отдельно, чтобы конфликты имён между наборами не мешали друг другу.
+func (usecase *GRPCUsecase) findMethod(service, method string) (protoreflect.MethodDescriptor, error) {
+	if service == "" || method == "" {
+		return nil, nil
+	}
+
+	descriptors, err := usecase.repository.GetDescriptors()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get descriptors: %v", err)
+	}
+
+	for _, descriptor := range descriptors {
+		files, err := parseDescriptorSet(descriptor.Data)
+		if err != nil {
+			log.Printf("Skipping descriptor set %s: %v", descriptor.ID.Hex(), err)
+			continue
+		}
+
+		found, err := files.FindDescriptorByName(protoreflect.FullName(service))
+		if err != nil {
+			continue
+		}
+		if serviceDescriptor, ok := found.(protoreflect.ServiceDescriptor); ok {
+			if methodDescriptor := serviceDescriptor.Methods().ByName(protoreflect.Name(method)); methodDescriptor != nil {
+				return methodDescriptor, nil
+			}
+		}
+	}
+
+	return nil, nil
+}
+
+// decodeMessages декодирует сообщения тела и возвращает трейлеры gRPC-Web, если они есть.
+func (usecase *GRPCUsecase) decodeMessages(body, encoding string, descriptor protoreflect.MessageDescriptor) ([]grpcEntity.Message, map[string]string, error) {
+	frames, parseErr := grpcframe.ParseMessages([]byte(body), encoding, usecase.bodyCaptureLimit)
+
+	var trailers map[string]string
+	messages := make([]grpcEntity.Message, 0, len(frames))
+	for _, frame := range frames {
+		if frame.Trailer {
+			trailers = grpcframe.ParseTrailers(frame.Data)
+			continue
+		}
+
+		message := grpcEntity.Message{Compressed: frame.Compressed}
+
+		if descriptor != nil {
+			if decoded, err := grpcframe.Decode(frame.Data, descriptor); err == nil {
+				message.JSON = decoded
+				message.Schema = true
+			}
+		}
+
+		if !message.Schema {
+			decoded, err := grpcframe.DecodeRaw(frame.Data)
+			if err != nil {
+				return messages, trailers, fmt.Errorf("failed to decode message: %v", err)
+			}
+			message.JSON = decoded
+		}
+
+		messages = append(messages, message)
+	}
+
+	return messages, trailers, parseErr
+}
+
+func parseDescriptorSet(data []byte) (*protoregistry.Files, error) {
+	var set descriptorpb.FileDescriptorSet
+	if err := proto.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal FileDescriptorSet: %v", err)
+	}
+
+	if len(set.File) == 0 {
+		return nil, fmt.Errorf("descriptor set is empty")
+	}
+
+	files, err := protodesc.NewFiles(&set)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build file descriptors: %v", err)
+	}
+	return files, nil
+}
@@ -0,0 +1,11 @@
+package passthrough
+
+import (
+	"net/http"
+)
+
+type Handlers interface {
+	GetHosts(w http.ResponseWriter, r *http.Request)
+	Reset(w http.ResponseWriter, r *http.Request)
+	ResetAll(w http.ResponseWriter, r *http.Request)
+}
@@ -0,0 +1,68 @@
+package http
+
+import (
+	"html/template"
+	"log"
+	"net/http"
+
+	"github.com/gorilla/mux"
+
+	"github.com/bocharovatd/mitm-proxy/internal/passthrough"
+	passthroughEntity "github.com/bocharovatd/mitm-proxy/internal/passthrough/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/websession"
+)
+
+type PassthroughHandlers struct {
+	usecase passthrough.Usecase
+	tmpl    *template.Template
+}
+
+func NewPassthroughHandlers(passthroughUC passthrough.Usecase) passthrough.Handlers {
+	tmpl := template.Must(template.ParseGlob("templates/*.html"))
+	return &PassthroughHandlers{
+		usecase: passthroughUC,
+		tmpl:    tmpl,
+	}
+}
+
+func (handlers *PassthroughHandlers) GetHosts(w http.ResponseWriter, r *http.Request) {
+	hosts, err := handlers.usecase.GetHosts()
+	if err != nil {
+		log.Printf("Failed to get passthrough hosts: %v", err)
+		return
+	}
+
+	data := struct {
+		Title     string
+		Hosts     []*passthroughEntity.Host
+		CSRFToken string
+	}{
+		Title:     "TLS Passthrough",
+		Hosts:     hosts,
+		CSRFToken: websession.CSRFToken(r),
+	}
+
+	if err := handlers.tmpl.ExecuteTemplate(w, "passthrough.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+		return
+	}
+}
+
+func (handlers *PassthroughHandlers) Reset(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	host := vars["host"]
+
+	if err := handlers.usecase.Reset(host); err != nil {
+		log.Printf("Failed to reset passthrough host: %v", err)
+	}
+
+	http.Redirect(w, r, "/passthrough", http.StatusSeeOther)
+}
+
+func (handlers *PassthroughHandlers) ResetAll(w http.ResponseWriter, r *http.Request) {
+	if err := handlers.usecase.ResetAll(); err != nil {
+		log.Printf("Failed to reset passthrough hosts: %v", err)
+	}
+
+	http.Redirect(w, r, "/passthrough", http.StatusSeeOther)
+}
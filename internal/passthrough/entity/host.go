@@ -0,0 +1,23 @@
+package entity
+
+import (
+	"errors"
+	"time"
+)
+
+// ErrClosedAfterHandshake — клиент завершил рукопожатие с сертификатом прокси, но
+// сразу закрыл соединение, не отправив данных. Так ведут себя клиенты с certificate
+// pinning, проверяющие сертификат после рукопожатия.
+var ErrClosedAfterHandshake = errors.New("client closed the connection right after the handshake")
+
+// Host — хост, клиенты которого не принимают сертификаты прокси. После заданного
+// числа неудачных рукопожатий TLS с ним передаётся без расшифровки.
+type Host struct {
+	Host        string    `bson:"host"`
+	Failures    int       `bson:"failures"`
+	LastError   string    `bson:"last_error"`
+	LastFailure time.Time `bson:"last_failure"`
+	Passthrough bool      `bson:"passthrough"`
+	// Since — время переключения хоста на передачу без расшифровки
+	Since time.Time `bson:"since,omitempty"`
+}
@@ -0,0 +1,18 @@
+package passthrough
+
+import (
+	"time"
+
+	passthroughEntity "github.com/bocharovatd/mitm-proxy/internal/passthrough/entity"
+)
+
+type Repository interface {
+	RecordFailure(host, reason string, since time.Time) (*passthroughEntity.Host, error)
+	ClearFailures(host string) error
+	SetPassthrough(host string) error
+	GetPassthroughHosts() ([]string, error)
+	GetHosts() ([]*passthroughEntity.Host, error)
+	Delete(host string) error
+	DeleteAll() error
+	Migrate() error
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+
+	"github.com/bocharovatd/mitm-proxy/internal/passthrough"
+	passthroughEntity "github.com/bocharovatd/mitm-proxy/internal/passthrough/entity"
+)
+
+type PassthroughRepository struct {
+	mongoCollection *mongo.Collection
+}
+
+func NewPassthroughRepository(mongoClient *mongo.Client) passthrough.Repository {
+	collection := mongoClient.Database("MongoBD").Collection("passthrough_hosts")
+	return &PassthroughRepository{mongoCollection: collection}
+}
+
+// RecordFailure увеличивает счётчик неудачных рукопожатий хоста и возвращает запись
+// после обновления. Если предыдущий отказ был раньше since, счёт начинается заново.
+func (repository *PassthroughRepository) RecordFailure(host, reason string, since time.Time) (*passthroughEntity.Host, error) {
+	recent := bson.D{{Key: "$gte", Value: bson.A{"$last_failure", since}}}
+	increment := bson.D{{Key: "$add", Value: bson.A{bson.D{{Key: "$ifNull", Value: bson.A{"$failures", 0}}}, 1}}}
+	update := mongo.Pipeline{{{Key: "$set", Value: bson.D{
+		{Key: "failures", Value: bson.D{{Key: "$cond", Value: bson.A{recent, increment, 1}}}},
+		{Key: "last_error", Value: reason},
+		{Key: "last_failure", Value: time.Now()},
+	}}}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var record passthroughEntity.Host
+	err := repository.mongoCollection.FindOneAndUpdate(context.Background(), bson.M{"host": host}, update, opts).Decode(&record)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record handshake failure: %v", err)
+	}
+
+	return &record, nil
+}
+
+func (repository *PassthroughRepository) SetPassthrough(host string) error {
+	update := bson.M{"$set": bson.M{"passthrough": true, "since": time.Now()}}
+	if _, err := repository.mongoCollection.UpdateOne(context.Background(), bson.M{"host": host}, update); err != nil {
+		return fmt.Errorf("failed to switch host to passthrough: %v", err)
+	}
+	return nil
+}
+
+// ClearFailures удаляет запись хоста, ещё не переключённого на передачу без расшифровки.
+func (repository *PassthroughRepository) ClearFailures(host string) error {
+	filter := bson.M{"host": host, "passthrough": bson.M{"$ne": true}}
+	if _, err := repository.mongoCollection.DeleteOne(context.Background(), filter); err != nil {
+		return fmt.Errorf("failed to clear handshake failures: %v", err)
+	}
+	return nil
+}
+
+func (repository *PassthroughRepository) GetPassthroughHosts() ([]string, error) {
+	opts := options.Find().SetProjection(bson.M{"host": 1})
+	cursor, err := repository.mongoCollection.Find(context.Background(), bson.M{"passthrough": true}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get passthrough hosts: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	var hosts []string
+	for cursor.Next(context.Background()) {
+		var host passthroughEntity.Host
+		if err := cursor.Decode(&host); err != nil {
+			return nil, fmt.Errorf("failed to decode host: %v", err)
+		}
+		hosts = append(hosts, host.Host)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error while getting passthrough hosts: %v", err)
+	}
+
+	return hosts, nil
+}
+
+func (repository *PassthroughRepository) GetHosts() ([]*passthroughEntity.Host, error) {
+	var hosts []*passthroughEntity.Host
+
+	opts := options.Find().SetSort(bson.D{{Key: "last_failure", Value: -1}})
+	cursor, err := repository.mongoCollection.Find(context.Background(), bson.D{}, opts)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hosts: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var host passthroughEntity.Host
+		if err := cursor.Decode(&host); err != nil {
+			return nil, fmt.Errorf("failed to decode host: %v", err)
+		}
+		hosts = append(hosts, &host)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error while getting hosts: %v", err)
+	}
+
+	return hosts, nil
+}
+
+func (repository *PassthroughRepository) Delete(host string) error {
+	if _, err := repository.mongoCollection.DeleteOne(context.Background(), bson.M{"host": host}); err != nil {
+		return fmt.Errorf("failed to delete host: %v", err)
+	}
+	return nil
+}
+
+func (repository *PassthroughRepository) DeleteAll() error {
+	if _, err := repository.mongoCollection.DeleteMany(context.Background(), bson.D{}); err != nil {
+		return fmt.Errorf("failed to delete hosts: %v", err)
+	}
+	return nil
+}
+
+// Migrate создаёт уникальный индекс по host.
+func (repository *PassthroughRepository) Migrate() error {
+	index := mongo.IndexModel{
+		Keys:    bson.D{{Key: "host", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}
+
+	if _, err := repository.mongoCollection.Indexes().CreateOne(context.Background(), index); err != nil {
+		return fmt.Errorf("failed to create passthrough host index: %w", err)
+	}
+
+	return nil
+}
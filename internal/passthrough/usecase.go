@@ -0,0 +1,14 @@
+package passthrough
+
+import (
+	passthroughEntity "github.com/bocharovatd/mitm-proxy/internal/passthrough/entity"
+)
+
+type Usecase interface {
+	RecordFailure(host string, handshakeErr error) (bool, error)
+	RecordSuccess(host string) error
+	IsPassthrough(host string) bool
+	GetHosts() ([]*passthroughEntity.Host, error)
+	Reset(host string) error
+	ResetAll() error
+}
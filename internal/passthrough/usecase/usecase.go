@@ -0,0 +1,200 @@
+package usecase
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bocharovatd/mitm-proxy/internal/passthrough"
+	passthroughEntity "github.com/bocharovatd/mitm-proxy/internal/passthrough/entity"
+)
+
+const (
+	// failureWindow — отказы старше этого срока не учитываются.
+	failureWindow = 10 * time.Minute
+	// refreshInterval — как часто перечитывается список переключённых хостов: сброс
+	// из веб-интерфейса выполняется другим экземпляром usecase.
+	refreshInterval = 5 * time.Second
+)
+
+type PassthroughUsecase struct {
+	repository passthrough.Repository
+	threshold  int
+
+	mu sync.RWMutex
+	// learned — переключённые хосты; проверка при каждом CONNECT не обращается к базе
+	learned  map[string]bool
+	loadedAt time.Time
+	// failing — хосты с учтёнными отказами, счётчик которых сбрасывает успешное рукопожатие
+	failing    map[string]bool
+	refreshing atomic.Bool
+}
+
+// NewPassthroughUsecase создаёт usecase, переключающий хост на передачу без расшифровки
+// после threshold отказов клиента. При threshold <= 0 отказы не учитываются.
+func NewPassthroughUsecase(repo passthrough.Repository, threshold int) passthrough.Usecase {
+	return &PassthroughUsecase{
+		repository: repo,
+		threshold:  threshold,
+		learned:    make(map[string]bool),
+		failing:    make(map[string]bool),
+	}
+}
+
+// RecordFailure учитывает отказ клиента от сертификата прокси и сообщает, что хост
+// только что переключён на передачу без расшифровки.
+func (usecase *PassthroughUsecase) RecordFailure(host string, handshakeErr error) (bool, error) {
+	if usecase.threshold <= 0 || host == "" || !isCertificateRejection(handshakeErr) {
+		return false, nil
+	}
+
+	record, err := usecase.repository.RecordFailure(host, handshakeErr.Error(), time.Now().Add(-failureWindow))
+	if err != nil {
+		return false, fmt.Errorf("failed to record handshake failure for %s: %v", host, err)
+	}
+
+	usecase.mu.Lock()
+	usecase.failing[host] = true
+	usecase.mu.Unlock()
+
+	if record.Passthrough || record.Failures < usecase.threshold {
+		return false, nil
+	}
+
+	if err := usecase.repository.SetPassthrough(host); err != nil {
+		return false, fmt.Errorf("failed to switch %s to passthrough: %v", host, err)
+	}
+
+	usecase.mu.Lock()
+	usecase.learned[host] = true
+	delete(usecase.failing, host)
+	usecase.mu.Unlock()
+
+	return true, nil
+}
+
+// RecordSuccess сбрасывает отказы хоста после успешного рукопожатия. К базе
+// обращается, только если у хоста были отказы.
+func (usecase *PassthroughUsecase) RecordSuccess(host string) error {
+	usecase.mu.RLock()
+	failing := usecase.failing[host]
+	usecase.mu.RUnlock()
+	if !failing {
+		return nil
+	}
+
+	if err := usecase.repository.ClearFailures(host); err != nil {
+		return fmt.Errorf("failed to clear handshake failures for %s: %v", host, err)
+	}
+
+	usecase.mu.Lock()
+	delete(usecase.failing, host)
+	usecase.mu.Unlock()
+	return nil
+}
+
+// IsPassthrough сообщает, что хост был переключён на передачу без расшифровки.
+// Список хранится в памяти и обновляется в фоне; до первой загрузки и при ошибке
+// базы соединение перехватывается как обычно.
+func (usecase *PassthroughUsecase) IsPassthrough(host string) bool {
+	if usecase.threshold <= 0 || host == "" {
+		return false
+	}
+
+	usecase.mu.RLock()
+	stale := time.Since(usecase.loadedAt) > refreshInterval
+	learned := usecase.learned[host]
+	usecase.mu.RUnlock()
+
+	if stale && usecase.refreshing.CompareAndSwap(false, true) {
+		go func() {
+			defer usecase.refreshing.Store(false)
+			usecase.refresh()
+		}()
+	}
+
+	return learned
+}
+
+func (usecase *PassthroughUsecase) refresh() {
+	hosts, err := usecase.repository.GetPassthroughHosts()
+
+	usecase.mu.Lock()
+	defer usecase.mu.Unlock()
+
+	// При ошибке следующая попытка — через refreshInterval
+	usecase.loadedAt = time.Now()
+	if err != nil {
+		log.Printf("Failed to load passthrough hosts: %v", err)
+		return
+	}
+
+	usecase.learned = make(map[string]bool, len(hosts))
+	for _, host := range hosts {
+		usecase.learned[host] = true
+	}
+}
+
+func (usecase *PassthroughUsecase) GetHosts() ([]*passthroughEntity.Host, error) {
+	hosts, err := usecase.repository.GetHosts()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get hosts: %v", err)
+	}
+	return hosts, nil
+}
+
+func (usecase *PassthroughUsecase) Reset(host string) error {
+	if err := usecase.repository.Delete(host); err != nil {
+		return fmt.Errorf("failed to reset %s: %v", host, err)
+	}
+
+	usecase.mu.Lock()
+	delete(usecase.learned, host)
+	delete(usecase.failing, host)
+	usecase.mu.Unlock()
+	return nil
+}
+
+func (usecase *PassthroughUsecase) ResetAll() error {
+	if err := usecase.repository.DeleteAll(); err != nil {
+		return fmt.Errorf("failed to reset hosts: %v", err)
+	}
+
+	usecase.mu.Lock()
+	usecase.learned = make(map[string]bool)
+	usecase.failing = make(map[string]bool)
+	usecase.mu.Unlock()
+	return nil
+}
+
+// isCertificateRejection отличает отказ клиента от сертификата прокси от обрывов
+// соединения, таймаутов и ошибок самого прокси. Клиент отказывает alert'ом
+// (bad_certificate, unknown_ca, certificate_unknown) или, как OpenSSL в TLS 1.3,
+// записью, которую сервер не может расшифровать (bad record MAC). Клиенты с
+// certificate pinning закрывают соединение сразу после рукопожатия
+// (ErrClosedAfterHandshake).
+func isCertificateRejection(err error) bool {
+	if errors.Is(err, passthroughEntity.ErrClosedAfterHandshake) {
+		return true
+	}
+
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) || opErr.Err == nil {
+		return false
+	}
+
+	switch opErr.Op {
+	case "remote error":
+		switch opErr.Err.Error() {
+		case "tls: bad certificate", "tls: unknown certificate authority", "tls: unknown certificate":
+			return true
+		}
+	case "local error":
+		return opErr.Err.Error() == "tls: bad record MAC"
+	}
+	return false
+}
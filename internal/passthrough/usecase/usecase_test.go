@@ -0,0 +1,45 @@
+package usecase
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"testing"
+
+	passthroughEntity "github.com/bocharovatd/mitm-proxy/internal/passthrough/entity"
+)
+
+func TestIsCertificateRejection(t *testing.T) {
+	alert := func(op string, code uint8) error {
+		return &net.OpError{Op: op, Net: "tcp", Err: tls.AlertError(code)}
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{name: "bad certificate", err: alert("remote error", 42), want: true},
+		{name: "certificate unknown", err: alert("remote error", 46), want: true},
+		{name: "unknown CA", err: alert("remote error", 48), want: true},
+		{name: "bad record MAC", err: alert("local error", 20), want: true},
+		{name: "wrapped rejection", err: fmt.Errorf("handshake: %w", alert("remote error", 48)), want: true},
+		{name: "closed after handshake", err: fmt.Errorf("%w: EOF", passthroughEntity.ErrClosedAfterHandshake), want: true},
+		{name: "handshake failure", err: alert("remote error", 40)},
+		{name: "protocol version", err: alert("remote error", 70)},
+		{name: "bad record MAC from peer", err: alert("remote error", 20)},
+		{name: "client closed connection", err: io.EOF},
+		{name: "reset by peer", err: &net.OpError{Op: "read", Net: "tcp", Err: errors.New("connection reset by peer")}},
+		{name: "certificate generation", err: errors.New("failed to generate certificate")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isCertificateRejection(tt.err); got != tt.want {
+				t.Errorf("isCertificateRejection(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
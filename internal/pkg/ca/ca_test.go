@@ -0,0 +1,159 @@
+package ca
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeAuthority сохраняет самоподписанный CA во временный каталог и возвращает пути к сертификату и ключу.
+func writeAuthority(t *testing.T) (string, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600)
+	return certPath, keyPath
+}
+
+func TestSign(t *testing.T) {
+	certPath, keyPath := writeAuthority(t)
+
+	tests := []struct {
+		name         string
+		keyAlgorithm string
+		hosts        []string
+		// verify — имена, для которых сертификат должен пройти проверку
+		verify  []string
+		wantKey string
+		wantDNS int
+		wantIPs int
+		wantRSA bool
+		wantErr bool
+	}{
+		{name: "domain", keyAlgorithm: KeyECDSAP256, hosts: []string{"example.com"}, verify: []string{"example.com"}, wantDNS: 1, wantKey: "*ecdsa.PrivateKey"},
+		{name: "wildcard", keyAlgorithm: KeyECDSAP384, hosts: []string{"example.com", "*.example.com"}, verify: []string{"example.com", "a.example.com"}, wantDNS: 2, wantKey: "*ecdsa.PrivateKey"},
+		{name: "IP address", keyAlgorithm: KeyEd25519, hosts: []string{"192.0.2.1", "2001:db8::1"}, verify: []string{"192.0.2.1", "2001:db8::1"}, wantIPs: 2, wantKey: "ed25519.PrivateKey"},
+		{name: "RSA key", keyAlgorithm: KeyRSA2048, hosts: []string{"example.com"}, verify: []string{"example.com"}, wantDNS: 1, wantRSA: true, wantKey: "*rsa.PrivateKey"},
+		{name: "no hosts", keyAlgorithm: KeyECDSAP256, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authority, err := Load(certPath, keyPath, 24*time.Hour, tt.keyAlgorithm)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			cert, err := authority.Sign(tt.hosts...)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Sign error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if len(cert.Certificate) != 2 || string(cert.Certificate[1]) != string(authority.cert.Raw) {
+				t.Fatalf("chain has %d certificates, want leaf and CA", len(cert.Certificate))
+			}
+			if cert.Leaf == nil || string(cert.Leaf.Raw) != string(cert.Certificate[0]) {
+				t.Fatalf("Leaf does not match the first certificate in the chain")
+			}
+			if got := fmt.Sprintf("%T", cert.PrivateKey); got != tt.wantKey {
+				t.Errorf("key type = %s, want %s", got, tt.wantKey)
+			}
+
+			leaf := cert.Leaf
+			if leaf.Subject.CommonName != tt.hosts[0] || len(leaf.DNSNames) != tt.wantDNS || len(leaf.IPAddresses) != tt.wantIPs {
+				t.Errorf("CN %q, %d DNS names, %d IPs, want %q, %d, %d",
+					leaf.Subject.CommonName, len(leaf.DNSNames), len(leaf.IPAddresses), tt.hosts[0], tt.wantDNS, tt.wantIPs)
+			}
+			if leaf.IsCA || leaf.NotAfter.After(time.Now().Add(24*time.Hour)) {
+				t.Errorf("IsCA %v, NotAfter %v", leaf.IsCA, leaf.NotAfter)
+			}
+			if got := leaf.KeyUsage&x509.KeyUsageKeyEncipherment != 0; got != tt.wantRSA {
+				t.Errorf("key encipherment usage = %v, want %v", got, tt.wantRSA)
+			}
+
+			roots := x509.NewCertPool()
+			roots.AddCert(authority.cert)
+			for _, name := range tt.verify {
+				if _, err := leaf.Verify(x509.VerifyOptions{DNSName: name, Roots: roots}); err != nil {
+					t.Errorf("Verify(%s): %v", name, err)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadUnsupportedAlgorithm(t *testing.T) {
+	certPath, keyPath := writeAuthority(t)
+	if _, err := Load(certPath, keyPath, time.Hour, "dsa"); err == nil {
+		t.Error("Load accepted an unsupported key algorithm")
+	}
+}
+
+func TestParsePrivateKey(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec1, _ := x509.MarshalECPrivateKey(ecKey)
+	pkcs8, _ := x509.MarshalPKCS8PrivateKey(ecKey)
+
+	tests := []struct {
+		name    string
+		der     []byte
+		wantErr bool
+	}{
+		{name: "PKCS1", der: x509.MarshalPKCS1PrivateKey(rsaKey)},
+		{name: "PKCS8", der: pkcs8},
+		{name: "SEC1", der: sec1},
+		{name: "garbage", der: []byte("not a key"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key, err := ParsePrivateKey(tt.der)
+			if (err != nil) != tt.wantErr || (!tt.wantErr && key == nil) {
+				t.Errorf("ParsePrivateKey = %T, %v", key, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,107 @@
+package certcache
+
+import (
+	"container/list"
+	"crypto/tls"
+	"crypto/x509"
+	"expvar"
+	"sync"
+	"time"
+)
+
+// Сертификат считается просроченным за сутки до NotAfter, как и в ProxyUsecase.
+const renewBefore = 24 * time.Hour
+
+var stats = expvar.NewMap("certificate_cache")
+
+type entry struct {
+	domain   string
+	cert     tls.Certificate
+	notAfter time.Time
+}
+
+type Cache struct {
+	mu      sync.Mutex
+	size    int
+	entries map[string]*list.Element
+	order   *list.List
+}
+
+func New(size int) *Cache {
+	return &Cache{
+		size:    size,
+		entries: make(map[string]*list.Element),
+		order:   list.New(),
+	}
+}
+
+func (c *Cache) Get(domain string) (tls.Certificate, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[domain]
+	if !ok {
+		stats.Add("misses", 1)
+		return tls.Certificate{}, false
+	}
+
+	e := elem.Value.(*entry)
+	if !e.notAfter.After(time.Now().Add(renewBefore)) {
+		c.removeElement(elem)
+		stats.Add("expired", 1)
+		stats.Add("misses", 1)
+		return tls.Certificate{}, false
+	}
+
+	c.order.MoveToFront(elem)
+	stats.Add("hits", 1)
+	return e.cert, true
+}
+
+func (c *Cache) Add(domain string, cert tls.Certificate) {
+	notAfter, ok := certificateNotAfter(cert)
+	if !ok || c.size <= 0 {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if elem, ok := c.entries[domain]; ok {
+		elem.Value = &entry{domain: domain, cert: cert, notAfter: notAfter}
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[domain] = c.order.PushFront(&entry{domain: domain, cert: cert, notAfter: notAfter})
+
+	for c.order.Len() > c.size {
+		c.removeElement(c.order.Back())
+		stats.Add("evictions", 1)
+	}
+}
+
+func (c *Cache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.order.Len()
+}
+
+func (c *Cache) removeElement(elem *list.Element) {
+	c.order.Remove(elem)
+	delete(c.entries, elem.Value.(*entry).domain)
+}
+
+func certificateNotAfter(cert tls.Certificate) (time.Time, bool) {
+	if cert.Leaf != nil {
+		return cert.Leaf.NotAfter, true
+	}
+	if len(cert.Certificate) == 0 {
+		return time.Time{}, false
+	}
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return leaf.NotAfter, true
+}
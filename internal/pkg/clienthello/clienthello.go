@@ -0,0 +1,64 @@
+package clienthello
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+)
+
+var errHelloRead = errors.New("client hello read")
+
+// PeekServerName читает ClientHello и возвращает SNI, не начиная рукопожатие.
+// Прочитанные байты не теряются: возвращаемое соединение сначала отдаёт их,
+// поэтому его можно передать как в tls.Server, так и в туннель без расшифровки.
+func PeekServerName(conn net.Conn) (string, net.Conn, error) {
+	var buffered bytes.Buffer
+	var serverName string
+
+	sniff := &sniffConn{Conn: conn, reader: io.TeeReader(conn, &buffered)}
+	err := tls.Server(sniff, &tls.Config{
+		GetConfigForClient: func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			serverName = hello.ServerName
+			return nil, errHelloRead
+		},
+	}).Handshake()
+
+	replay := &replayConn{Conn: conn, reader: io.MultiReader(&buffered, conn)}
+	if !errors.Is(err, errHelloRead) {
+		return "", replay, err
+	}
+	return serverName, replay, nil
+}
+
+// sniffConn только читает: ответы tls.Server клиенту не отправляются.
+type sniffConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *sniffConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *sniffConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+type replayConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *replayConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// CloseWrite закрывает соединение на запись, если это поддерживает исходное соединение.
+func (c *replayConn) CloseWrite() error {
+	if closer, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return closer.CloseWrite()
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package clienthello
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/binary"
+	"io"
+	"net"
+	"testing"
+)
+
+// captureConn записывает всё, что отправляет клиент, и обрывает рукопожатие на чтении.
+type captureConn struct {
+	net.Conn
+	written bytes.Buffer
+}
+
+func (c *captureConn) Write(p []byte) (int, error) { return c.written.Write(p) }
+func (c *captureConn) Read([]byte) (int, error)    { return 0, io.EOF }
+
+func clientHello(t *testing.T, serverName string) []byte {
+	t.Helper()
+	conn := &captureConn{}
+	tls.Client(conn, &tls.Config{ServerName: serverName, InsecureSkipVerify: true}).Handshake()
+	if conn.written.Len() == 0 {
+		t.Fatal("client sent no ClientHello")
+	}
+	return conn.written.Bytes()
+}
+
+// splitRecord делит единственную TLS-запись с ClientHello на две записи.
+func splitRecord(record []byte) []byte {
+	payload := record[5:]
+	half := len(payload) / 2
+
+	var out []byte
+	for _, part := range [][]byte{payload[:half], payload[half:]} {
+		out = append(out, record[0], record[1], record[2])
+		out = binary.BigEndian.AppendUint16(out, uint16(len(part)))
+		out = append(out, part...)
+	}
+	return out
+}
+
+func TestPeekServerName(t *testing.T) {
+	hello := clientHello(t, "example.com")
+
+	tests := []struct {
+		name string
+		data []byte
+		// chunk — размер отдельных записей в соединение, 0 — всё сразу
+		chunk    int
+		wantName string
+		wantErr  bool
+	}{
+		{name: "server name", data: hello, wantName: "example.com"},
+		{name: "hello followed by application data", data: append(append([]byte{}, hello...), "tail"...), wantName: "example.com"},
+		{name: "missing SNI", data: clientHello(t, "")},
+		{name: "hello split across records", data: splitRecord(hello), wantName: "example.com"},
+		{name: "hello split across reads", data: hello, chunk: 7, wantName: "example.com"},
+		{name: "truncated hello", data: hello[:len(hello)/2], wantErr: true},
+		{name: "truncated record header", data: hello[:3], wantErr: true},
+		{name: "not TLS", data: []byte("GET / HTTP/1.1\r\nHost: example.com\r\n\r\n"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client, server := net.Pipe()
+			go func() {
+				data := tt.data
+				for len(data) > 0 {
+					n := len(data)
+					if tt.chunk > 0 && tt.chunk < n {
+						n = tt.chunk
+					}
+					if _, err := client.Write(data[:n]); err != nil {
+						return
+					}
+					data = data[n:]
+				}
+				client.Close()
+			}()
+
+			serverName, replay, err := PeekServerName(server)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if serverName != tt.wantName {
+				t.Errorf("server name = %q, want %q", serverName, tt.wantName)
+			}
+
+			// Прочитанные байты должны вернуться без потерь
+			replayed, _ := io.ReadAll(replay)
+			if !bytes.Equal(replayed, tt.data) {
+				t.Errorf("replayed %d bytes, want %d", len(replayed), len(tt.data))
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package grpc
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"unicode"
+	"unicode/utf8"
+
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+	"google.golang.org/protobuf/types/dynamicpb"
+)
+
+// DecodeRaw декодирует сообщение без схемы по wire-формату protobuf и возвращает JSON,
+// в котором ключи — номера полей. Length-delimited поля декодируются как вложенные
+// сообщения, если это возможно, иначе как строка UTF-8 или base64.
+func DecodeRaw(data []byte) (string, error) {
+	fields, err := decodeFields(data, 0)
+	if err != nil {
+		return "", err
+	}
+
+	result, err := json.MarshalIndent(fields, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// Decode декодирует сообщение по дескриптору типа и возвращает JSON.
+func Decode(data []byte, descriptor protoreflect.MessageDescriptor) (string, error) {
+	message := dynamicpb.NewMessage(descriptor)
+	if err := proto.Unmarshal(data, message); err != nil {
+		return "", err
+	}
+
+	result, err := protojson.MarshalOptions{Multiline: true, Indent: "  "}.Marshal(message)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// maxDepth ограничивает вложенность при попытке декодировать байты как сообщение.
+const maxDepth = 32
+
+func decodeFields(data []byte, depth int) (map[string][]interface{}, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("message is nested too deeply")
+	}
+
+	fields := make(map[string][]interface{})
+
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return nil, protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		var value interface{}
+		switch wireType {
+		case protowire.VarintType:
+			v, n := protowire.ConsumeVarint(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			value, data = v, data[n:]
+		case protowire.Fixed32Type:
+			v, n := protowire.ConsumeFixed32(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			value, data = v, data[n:]
+		case protowire.Fixed64Type:
+			v, n := protowire.ConsumeFixed64(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			value, data = v, data[n:]
+		case protowire.BytesType:
+			v, n := protowire.ConsumeBytes(data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			value, data = decodeBytes(v, depth), data[n:]
+		case protowire.StartGroupType:
+			v, n := protowire.ConsumeGroup(number, data)
+			if n < 0 {
+				return nil, protowire.ParseError(n)
+			}
+			value, data = decodeBytes(v, depth), data[n:]
+		default:
+			return nil, fmt.Errorf("unknown wire type %d", wireType)
+		}
+
+		key := strconv.Itoa(int(number))
+		fields[key] = append(fields[key], value)
+	}
+
+	return fields, nil
+}
+
+// decodeBytes выбирает представление length-delimited поля: печатный текст,
+// вложенное сообщение или base64.
+func decodeBytes(data []byte, depth int) interface{} {
+	if isPrintable(data) {
+		return string(data)
+	}
+	if nested, err := decodeFields(data, depth+1); err == nil && len(nested) > 0 {
+		return nested
+	}
+	if utf8.Valid(data) {
+		return string(data)
+	}
+	return base64.StdEncoding.EncodeToString(data)
+}
+
+func isPrintable(data []byte) bool {
+	if !utf8.Valid(data) {
+		return false
+	}
+	for _, r := range string(data) {
+		if !unicode.IsPrint(r) && !unicode.IsSpace(r) {
+			return false
+		}
+	}
+	return true
+}
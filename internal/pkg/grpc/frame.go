@@ -0,0 +1,102 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strings"
+)
+
+// Message — одно сообщение из тела gRPC-запроса или ответа.
+type Message struct {
+	Compressed bool
+	// Trailer — кадр трейлеров gRPC-Web: Data содержит заголовки в текстовом виде
+	Trailer bool
+	Data    []byte
+}
+
+const (
+	flagCompressed = 0x01
+	flagTrailer    = 0x80
+)
+
+// IsGRPC сообщает, что тело с таким Content-Type использует gRPC-фрейминг.
+func IsGRPC(contentType string) bool {
+	return strings.HasPrefix(contentType, "application/grpc") && !strings.HasPrefix(contentType, "application/grpc-web-text")
+}
+
+// ParseMessages разбирает тело на сообщения с 5-байтовым префиксом (флаги и длина).
+// Сжатые сообщения распаковываются, если encoding равен gzip, но не больше limit байт.
+// Неполное последнее сообщение (например, в обрезанном теле) возвращается вместе с ошибкой.
+func ParseMessages(body []byte, encoding string, limit int64) ([]Message, error) {
+	var messages []Message
+
+	for len(body) > 0 {
+		if len(body) < 5 {
+			return messages, fmt.Errorf("incomplete message prefix")
+		}
+
+		flags := body[0]
+		if flags&^(flagCompressed|flagTrailer) != 0 {
+			return messages, fmt.Errorf("unknown message flags 0x%02x", flags)
+		}
+		compressed := flags&flagCompressed != 0
+		length := binary.BigEndian.Uint32(body[1:5])
+		body = body[5:]
+
+		if uint64(length) > uint64(len(body)) {
+			return messages, fmt.Errorf("incomplete message: expected %d bytes, got %d", length, len(body))
+		}
+
+		data := body[:length]
+		body = body[length:]
+
+		if compressed {
+			var err error
+			if data, err = decompress(data, encoding, limit); err != nil {
+				return messages, err
+			}
+		}
+
+		messages = append(messages, Message{Compressed: compressed, Trailer: flags&flagTrailer != 0, Data: data})
+	}
+
+	return messages, nil
+}
+
+func decompress(data []byte, encoding string, limit int64) ([]byte, error) {
+	if encoding != "gzip" {
+		return nil, fmt.Errorf("unsupported message encoding %q", encoding)
+	}
+
+	reader, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress message: %w", err)
+	}
+	defer reader.Close()
+
+	data, err = io.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decompress message: %w", err)
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("decompressed message exceeds %d bytes", limit)
+	}
+	return data, nil
+}
+
+// ParseTrailers разбирает кадр трейлеров gRPC-Web ("grpc-status: 0\r\n...").
+func ParseTrailers(data []byte) map[string]string {
+	trailers := make(map[string]string)
+	for _, line := range strings.Split(string(data), "\n") {
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		trailers[textproto.CanonicalMIMEHeaderKey(strings.TrimSpace(name))] = strings.TrimSpace(value)
+	}
+	return trailers
+}
@@ -0,0 +1,115 @@
+package grpc
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"strings"
+	"testing"
+)
+
+func frame(flags byte, data []byte) []byte {
+	out := []byte{flags}
+	out = binary.BigEndian.AppendUint32(out, uint32(len(data)))
+	return append(out, data...)
+}
+
+func gzipped(t *testing.T, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	writer := gzip.NewWriter(&buf)
+	writer.Write(data)
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestParseMessages(t *testing.T) {
+	trailer := []byte("grpc-status: 5\r\ngrpc-message: not%20found\r\n")
+
+	tests := []struct {
+		name     string
+		body     []byte
+		encoding string
+		// want — данные сообщений; кадр трейлеров помечается префиксом "T:"
+		want    []string
+		wantErr string
+	}{
+		{
+			name: "data messages",
+			body: append(frame(0, []byte("a")), frame(0, []byte("bc"))...),
+			want: []string{"a", "bc"},
+		},
+		{
+			name: "grpc-web trailer frame",
+			body: append(frame(0, []byte("a")), frame(0x80, trailer)...),
+			want: []string{"a", "T:" + string(trailer)},
+		},
+		{
+			name:     "compressed message",
+			body:     frame(1, gzipped(t, []byte("hello"))),
+			encoding: "gzip",
+			want:     []string{"hello"},
+		},
+		{
+			name:    "unknown flag",
+			body:    append(frame(0, []byte("a")), frame(0x02, []byte("b"))...),
+			want:    []string{"a"},
+			wantErr: "unknown message flags 0x02",
+		},
+		{
+			name:     "decompression limit",
+			body:     frame(1, gzipped(t, bytes.Repeat([]byte{0}, 1<<20))),
+			encoding: "gzip",
+			wantErr:  "decompressed message exceeds 1024 bytes",
+		},
+		{
+			name:    "compressed without encoding",
+			body:    frame(1, []byte("x")),
+			wantErr: `unsupported message encoding ""`,
+		},
+		{
+			name:    "truncated body",
+			body:    frame(0, []byte("abc"))[:6],
+			wantErr: "incomplete message: expected 3 bytes, got 1",
+		},
+		{
+			name:    "truncated prefix",
+			body:    []byte{0, 0},
+			wantErr: "incomplete message prefix",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			messages, err := ParseMessages(tt.body, tt.encoding, 1024)
+
+			if tt.wantErr == "" && err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || err.Error() != tt.wantErr) {
+				t.Fatalf("error = %v, want %q", err, tt.wantErr)
+			}
+
+			var got []string
+			for _, message := range messages {
+				data := string(message.Data)
+				if message.Trailer {
+					data = "T:" + data
+				}
+				got = append(got, data)
+			}
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Errorf("messages = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseTrailers(t *testing.T) {
+	trailers := ParseTrailers([]byte("grpc-status: 5\r\nGRPC-Message: not%20found\r\n"))
+	if trailers["Grpc-Status"] != "5" || trailers["Grpc-Message"] != "not%20found" {
+		t.Errorf("trailers = %v", trailers)
+	}
+}
@@ -0,0 +1,43 @@
+package hostmatch
+
+import (
+	"path"
+	"strings"
+)
+
+// List — список шаблонов хостов: точных имён или масок вида *.example.com.
+type List struct {
+	patterns []string
+}
+
+func New(patterns []string) *List {
+	list := &List{}
+	for _, pattern := range patterns {
+		if pattern = normalize(pattern); pattern != "" {
+			list.patterns = append(list.patterns, pattern)
+		}
+	}
+	return list
+}
+
+// Match сообщает, что хост попадает под один из шаблонов.
+func (l *List) Match(host string) bool {
+	host = normalize(host)
+	if host == "" {
+		return false
+	}
+
+	for _, pattern := range l.patterns {
+		if pattern == host {
+			return true
+		}
+		if matched, _ := path.Match(pattern, host); matched {
+			return true
+		}
+	}
+	return false
+}
+
+func normalize(host string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(host)), ".")
+}
@@ -0,0 +1,33 @@
+package hostmatch
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		host     string
+		want     bool
+	}{
+		{name: "exact name", patterns: []string{"example.com"}, host: "example.com", want: true},
+		{name: "case and trailing dot", patterns: []string{" Example.COM. "}, host: "EXAMPLE.com.", want: true},
+		{name: "other name", patterns: []string{"example.com"}, host: "example.org"},
+		{name: "mask matches subdomain", patterns: []string{"*.example.com"}, host: "a.example.com", want: true},
+		{name: "mask does not match domain itself", patterns: []string{"*.example.com"}, host: "example.com"},
+		{name: "mask matches nested subdomain", patterns: []string{"*.example.com"}, host: "a.b.example.com", want: true},
+		{name: "mask for any host", patterns: []string{"*"}, host: "example.com", want: true},
+		{name: "IP address", patterns: []string{"192.0.2.1"}, host: "192.0.2.1", want: true},
+		{name: "second pattern", patterns: []string{"example.org", "*.example.com"}, host: "api.example.com", want: true},
+		{name: "empty patterns are skipped", patterns: []string{"", " "}, host: ""},
+		{name: "empty host", patterns: []string{"*"}, host: ""},
+		{name: "invalid mask", patterns: []string{"[example.com"}, host: "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := New(tt.patterns).Match(tt.host); got != tt.want {
+				t.Errorf("Match(%q) with %q = %v, want %v", tt.host, tt.patterns, got, tt.want)
+			}
+		})
+	}
+}
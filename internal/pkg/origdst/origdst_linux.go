@@ -0,0 +1,81 @@
+// Package origdst определяет исходный адрес назначения соединения, перенаправленного
+// на прокси правилом iptables REDIRECT или DNAT.
+package origdst
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"syscall"
+)
+
+// SO_ORIGINAL_DST и IP6T_SO_ORIGINAL_DST из linux/netfilter_ipv4.h и linux/netfilter_ipv6/ip6_tables.h
+const soOriginalDst = 80
+
+// Lookup возвращает адрес, к которому клиент подключался до перенаправления.
+// Для соединений без NAT ядро возвращает ошибку.
+func Lookup(conn net.Conn) (*net.TCPAddr, error) {
+	tcpConn, ok := conn.(*net.TCPConn)
+	if !ok {
+		return nil, errors.New("original destination requires a TCP connection")
+	}
+
+	local, ok := tcpConn.LocalAddr().(*net.TCPAddr)
+	if !ok {
+		return nil, errors.New("original destination requires a TCP connection")
+	}
+
+	rawConn, err := tcpConn.SyscallConn()
+	if err != nil {
+		return nil, fmt.Errorf("failed to access socket: %w", err)
+	}
+
+	var addr *net.TCPAddr
+	var sockErr error
+	err = rawConn.Control(func(fd uintptr) {
+		if local.IP.To4() != nil {
+			addr, sockErr = lookupIPv4(int(fd))
+		} else {
+			addr, sockErr = lookupIPv6(int(fd))
+		}
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to access socket: %w", err)
+	}
+	if sockErr != nil {
+		return nil, fmt.Errorf("failed to get original destination: %w", sockErr)
+	}
+	return addr, nil
+}
+
+// lookupIPv4 читает sockaddr_in: он помещается в структуру ipv6_mreq, для которой
+// в syscall есть обёртка getsockopt.
+func lookupIPv4(fd int) (*net.TCPAddr, error) {
+	mreq, err := syscall.GetsockoptIPv6Mreq(fd, syscall.SOL_IP, soOriginalDst)
+	if err != nil {
+		return nil, err
+	}
+	raw := mreq.Multiaddr
+	return &net.TCPAddr{
+		IP:   net.IPv4(raw[4], raw[5], raw[6], raw[7]),
+		Port: int(binary.BigEndian.Uint16(raw[2:4])),
+	}, nil
+}
+
+// lookupIPv6 читает sockaddr_in6 из начала структуры ip6_mtuinfo.
+func lookupIPv6(fd int) (*net.TCPAddr, error) {
+	info, err := syscall.GetsockoptIPv6MTUInfo(fd, syscall.SOL_IPV6, soOriginalDst)
+	if err != nil {
+		return nil, err
+	}
+
+	// Порт хранится в сетевом порядке байт
+	var port [2]byte
+	binary.NativeEndian.PutUint16(port[:], info.Addr.Port)
+
+	return &net.TCPAddr{
+		IP:   net.IP(info.Addr.Addr[:]),
+		Port: int(binary.BigEndian.Uint16(port[:])),
+	}, nil
+}
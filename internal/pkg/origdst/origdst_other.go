@@ -0,0 +1,13 @@
+//go:build !linux
+
+package origdst
+
+import (
+	"errors"
+	"net"
+)
+
+// Lookup на других системах не поддерживается: адрес назначения берётся из Host или SNI.
+func Lookup(conn net.Conn) (*net.TCPAddr, error) {
+	return nil, errors.New("original destination is supported only on Linux")
+}
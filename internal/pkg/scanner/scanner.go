@@ -1,8 +1,12 @@
 package scanner
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 )
 
@@ -12,19 +16,19 @@ type Scanner struct {
 }
 
 type InjectionPoint struct {
-	Type  string // "query", "header", "cookie", "form"
+	Type  string // "query", "header", "cookie", "form", "json"
 	Name  string
 	Value string
 }
 
-func NewScanner() *Scanner {
+func NewScanner(transport http.RoundTripper) *Scanner {
 	return &Scanner{
 		testCommands: []string{
 			";cat /etc/passwd;",
 			"|cat /etc/passwd|",
 			"`cat /etc/passwd`",
 		},
-		client: &http.Client{},
+		client: &http.Client{Transport: transport},
 	}
 }
 
@@ -142,3 +146,143 @@ func updateBody(req *http.Request) {
 	req.Body = io.NopCloser(strings.NewReader(body))
 	req.ContentLength = int64(len(body))
 }
+
+var errPathNotFound = errors.New("json path not found")
+
+// ScanJSON возвращает строковые поля JSON-сообщения как точки внедрения.
+// Name — путь к полю через точку, элементы массивов обозначаются индексом;
+// точка и обратная косая черта в имени ключа экранируются: путь a\.b указывает на ключ "a.b".
+func (s *Scanner) ScanJSON(payload []byte) []InjectionPoint {
+	var document interface{}
+	if err := json.Unmarshal(payload, &document); err != nil {
+		return nil
+	}
+
+	var points []InjectionPoint
+	walkJSON(document, nil, func(path string, value string) {
+		points = append(points, InjectionPoint{
+			Type:  "json",
+			Name:  path,
+			Value: value,
+		})
+	})
+
+	return points
+}
+
+// TestJSONInjection подставляет тестовые команды в поле сообщения и передаёт
+// изменённое сообщение в send, который возвращает полученные в ответ данные.
+func (s *Scanner) TestJSONInjection(point InjectionPoint, payload []byte, send func([]byte) ([]byte, error)) (bool, error) {
+	for _, cmd := range s.testCommands {
+		var document interface{}
+		if err := json.Unmarshal(payload, &document); err != nil {
+			return false, err
+		}
+
+		document, err := setJSON(document, splitPath(point.Name), point.Value+cmd)
+		if err != nil {
+			return false, fmt.Errorf("failed to set %q: %w", point.Name, err)
+		}
+
+		modified, err := json.Marshal(document)
+		if err != nil {
+			return false, err
+		}
+
+		response, err := send(modified)
+		if err != nil {
+			return false, err
+		}
+
+		if strings.Contains(string(response), "root:") {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func walkJSON(value interface{}, path []string, visit func(path string, value string)) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, child := range v {
+			walkJSON(child, append(path[:len(path):len(path)], escapeKey(key)), visit)
+		}
+	case []interface{}:
+		for i, child := range v {
+			walkJSON(child, append(path[:len(path):len(path)], strconv.Itoa(i)), visit)
+		}
+	case string:
+		visit(strings.Join(path, "."), v)
+	}
+}
+
+// setJSON заменяет строку по пути path; если путь не ведёт к строке, возвращает ошибку,
+// чтобы не отправлять сообщение без подставленной команды.
+func setJSON(value interface{}, path []string, newValue string) (interface{}, error) {
+	if len(path) == 0 {
+		if _, ok := value.(string); !ok {
+			return nil, errPathNotFound
+		}
+		return newValue, nil
+	}
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		child, ok := v[path[0]]
+		if !ok {
+			return nil, errPathNotFound
+		}
+		updated, err := setJSON(child, path[1:], newValue)
+		if err != nil {
+			return nil, err
+		}
+		v[path[0]] = updated
+	case []interface{}:
+		i, err := strconv.Atoi(path[0])
+		if err != nil || i < 0 || i >= len(v) {
+			return nil, errPathNotFound
+		}
+		updated, err := setJSON(v[i], path[1:], newValue)
+		if err != nil {
+			return nil, err
+		}
+		v[i] = updated
+	default:
+		return nil, errPathNotFound
+	}
+	return value, nil
+}
+
+func escapeKey(key string) string {
+	key = strings.ReplaceAll(key, `\`, `\\`)
+	return strings.ReplaceAll(key, ".", `\.`)
+}
+
+// splitPath разбирает путь из экранированных ключей, собранный walkJSON.
+// Пустой путь обозначает корень документа.
+func splitPath(path string) []string {
+	if path == "" {
+		return nil
+	}
+
+	var (
+		parts   []string
+		current strings.Builder
+		escaped bool
+	)
+	for _, r := range path {
+		switch {
+		case escaped:
+			current.WriteRune(r)
+			escaped = false
+		case r == '\\':
+			escaped = true
+		case r == '.':
+			parts = append(parts, current.String())
+			current.Reset()
+		default:
+			current.WriteRune(r)
+		}
+	}
+	return append(parts, current.String())
+}
@@ -0,0 +1,75 @@
+package scanner
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestJSONInjectionPaths(t *testing.T) {
+	tests := []struct {
+		name    string
+		payload string
+		// path — имя точки внедрения; пусто — путь берётся из ScanJSON
+		path    string
+		want    string
+		wantErr error
+	}{
+		{name: "nested field", payload: `{"user":{"name":"a"}}`, want: `{"user":{"name":"a;cmd;"}}`},
+		{name: "array element", payload: `{"items":["a"]}`, want: `{"items":["a;cmd;"]}`},
+		{name: "dotted key", payload: `{"a.b":"a","a":{"b":"x"}}`, path: `a\.b`, want: `{"a":{"b":"x"},"a.b":"a;cmd;"}`},
+		{name: "backslash in key", payload: `{"a\\":{"b":"a"}}`, want: `{"a\\":{"b":"a;cmd;"}}`},
+		{name: "empty key", payload: `{"":{"":"a"}}`, want: `{"":{"":"a;cmd;"}}`},
+		{name: "root string", payload: `"a"`, want: `"a;cmd;"`},
+		{name: "missing key", payload: `{"a":"a"}`, path: "b", wantErr: errPathNotFound},
+		{name: "index out of range", payload: `["a"]`, path: "1", wantErr: errPathNotFound},
+		{name: "path through string", payload: `{"a":"a"}`, path: "a.b", wantErr: errPathNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Scanner{testCommands: []string{";cmd;"}}
+
+			point := InjectionPoint{Type: "json", Name: tt.path, Value: "a"}
+			if tt.path == "" {
+				points := s.ScanJSON([]byte(tt.payload))
+				if len(points) != 1 {
+					t.Fatalf("ScanJSON returned %d points, want 1", len(points))
+				}
+				point = points[0]
+			}
+
+			var sent []string
+			_, err := s.TestJSONInjection(point, []byte(tt.payload), func(payload []byte) ([]byte, error) {
+				sent = append(sent, string(payload))
+				return nil, nil
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.wantErr != nil {
+				if len(sent) != 0 {
+					t.Errorf("sent %q, want nothing", sent)
+				}
+				return
+			}
+
+			if len(sent) != 1 || !jsonEqual(t, sent[0], tt.want) {
+				t.Errorf("sent %s, want %s", strings.Join(sent, ", "), tt.want)
+			}
+		})
+	}
+}
+
+func jsonEqual(t *testing.T, a, b string) bool {
+	t.Helper()
+	var left, right interface{}
+	if err := json.Unmarshal([]byte(a), &left); err != nil {
+		t.Fatalf("invalid JSON %s: %v", a, err)
+	}
+	json.Unmarshal([]byte(b), &right)
+	normalizedLeft, _ := json.Marshal(left)
+	normalizedRight, _ := json.Marshal(right)
+	return string(normalizedLeft) == string(normalizedRight)
+}
@@ -0,0 +1,191 @@
+// Package socks5 реализует серверную часть рукопожатия SOCKS5 (RFC 1928) с
+// необязательной аутентификацией по имени и паролю (RFC 1929). Поддерживается
+// только команда CONNECT.
+package socks5
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+const (
+	version     = 0x05
+	authVersion = 0x01
+
+	methodNoAuth       = 0x00
+	methodPassword     = 0x02
+	methodNoAcceptable = 0xff
+
+	commandConnect = 0x01
+
+	addrIPv4   = 0x01
+	addrDomain = 0x03
+	addrIPv6   = 0x04
+
+	replySucceeded               = 0x00
+	replyCommandNotSupported     = 0x07
+	replyAddressTypeNotSupported = 0x08
+)
+
+var ErrAuthFailed = errors.New("socks5: authentication failed")
+
+// Authenticator проверяет имя и пароль клиента; nil отключает аутентификацию.
+type Authenticator func(username, password string) bool
+
+// Handshake проводит согласование с клиентом и возвращает адрес назначения
+// команды CONNECT в виде host:port и имя прошедшего аутентификацию пользователя.
+// Ответ об успехе отправляется сразу: соединение с целевым сервером
+// устанавливается позже вызывающей стороной.
+func Handshake(conn net.Conn, check Authenticator) (string, string, error) {
+	username, err := negotiate(conn, check)
+	if err != nil {
+		return "", "", err
+	}
+
+	header := make([]byte, 4)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", "", fmt.Errorf("socks5: failed to read request: %w", err)
+	}
+	if header[0] != version {
+		return "", "", fmt.Errorf("socks5: unsupported version %d", header[0])
+	}
+
+	host, err := readAddress(conn, header[3])
+	if err != nil {
+		if errors.Is(err, errAddressType) {
+			reply(conn, replyAddressTypeNotSupported)
+		}
+		return "", "", err
+	}
+
+	portBytes := make([]byte, 2)
+	if _, err := io.ReadFull(conn, portBytes); err != nil {
+		return "", "", fmt.Errorf("socks5: failed to read port: %w", err)
+	}
+	port := int(portBytes[0])<<8 | int(portBytes[1])
+
+	if header[1] != commandConnect {
+		reply(conn, replyCommandNotSupported)
+		return "", "", fmt.Errorf("socks5: unsupported command %d", header[1])
+	}
+
+	if err := reply(conn, replySucceeded); err != nil {
+		return "", "", fmt.Errorf("socks5: failed to send reply: %w", err)
+	}
+
+	return net.JoinHostPort(host, strconv.Itoa(port)), username, nil
+}
+
+// negotiate выбирает метод аутентификации и, если нужно, проверяет имя и пароль.
+func negotiate(conn net.Conn, check Authenticator) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", fmt.Errorf("socks5: failed to read greeting: %w", err)
+	}
+	if header[0] != version {
+		return "", fmt.Errorf("socks5: unsupported version %d", header[0])
+	}
+
+	methods := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return "", fmt.Errorf("socks5: failed to read methods: %w", err)
+	}
+
+	method := byte(methodNoAuth)
+	if check != nil {
+		method = methodPassword
+	}
+
+	offered := false
+	for _, m := range methods {
+		if m == method {
+			offered = true
+			break
+		}
+	}
+	if !offered {
+		conn.Write([]byte{version, methodNoAcceptable})
+		return "", fmt.Errorf("socks5: client does not support method %d", method)
+	}
+
+	if _, err := conn.Write([]byte{version, method}); err != nil {
+		return "", fmt.Errorf("socks5: failed to send method: %w", err)
+	}
+
+	if method == methodPassword {
+		return authenticate(conn, check)
+	}
+	return "", nil
+}
+
+func authenticate(conn net.Conn, check Authenticator) (string, error) {
+	header := make([]byte, 2)
+	if _, err := io.ReadFull(conn, header); err != nil {
+		return "", fmt.Errorf("socks5: failed to read credentials: %w", err)
+	}
+	if header[0] != authVersion {
+		return "", fmt.Errorf("socks5: unsupported auth version %d", header[0])
+	}
+
+	username := make([]byte, header[1])
+	if _, err := io.ReadFull(conn, username); err != nil {
+		return "", fmt.Errorf("socks5: failed to read username: %w", err)
+	}
+
+	length := make([]byte, 1)
+	if _, err := io.ReadFull(conn, length); err != nil {
+		return "", fmt.Errorf("socks5: failed to read password: %w", err)
+	}
+	password := make([]byte, length[0])
+	if _, err := io.ReadFull(conn, password); err != nil {
+		return "", fmt.Errorf("socks5: failed to read password: %w", err)
+	}
+
+	if !check(string(username), string(password)) {
+		conn.Write([]byte{authVersion, 0x01})
+		return "", fmt.Errorf("%w for user %q", ErrAuthFailed, username)
+	}
+
+	if _, err := conn.Write([]byte{authVersion, 0x00}); err != nil {
+		return "", fmt.Errorf("socks5: failed to send auth status: %w", err)
+	}
+	return string(username), nil
+}
+
+var errAddressType = errors.New("socks5: unsupported address type")
+
+func readAddress(conn net.Conn, addrType byte) (string, error) {
+	switch addrType {
+	case addrIPv4, addrIPv6:
+		size := net.IPv4len
+		if addrType == addrIPv6 {
+			size = net.IPv6len
+		}
+		ip := make(net.IP, size)
+		if _, err := io.ReadFull(conn, ip); err != nil {
+			return "", fmt.Errorf("socks5: failed to read address: %w", err)
+		}
+		return ip.String(), nil
+	case addrDomain:
+		length := make([]byte, 1)
+		if _, err := io.ReadFull(conn, length); err != nil {
+			return "", fmt.Errorf("socks5: failed to read address: %w", err)
+		}
+		domain := make([]byte, length[0])
+		if _, err := io.ReadFull(conn, domain); err != nil {
+			return "", fmt.Errorf("socks5: failed to read address: %w", err)
+		}
+		return string(domain), nil
+	default:
+		return "", fmt.Errorf("%w %d", errAddressType, addrType)
+	}
+}
+
+// reply отправляет ответ на запрос с нулевым адресом привязки.
+func reply(conn net.Conn, code byte) error {
+	_, err := conn.Write([]byte{version, code, 0x00, addrIPv4, 0, 0, 0, 0, 0, 0})
+	return err
+}
@@ -0,0 +1,131 @@
+package socks5
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+// scriptedConn отдаёт заранее подготовленные байты клиента и запоминает ответы сервера.
+type scriptedConn struct {
+	net.Conn
+	in  *bytes.Reader
+	out bytes.Buffer
+}
+
+func (c *scriptedConn) Read(p []byte) (int, error)  { return c.in.Read(p) }
+func (c *scriptedConn) Write(p []byte) (int, error) { return c.out.Write(p) }
+
+func join(parts ...[]byte) []byte {
+	return bytes.Join(parts, nil)
+}
+
+func TestHandshake(t *testing.T) {
+	check := func(username, password string) bool {
+		return username == "user" && password == "secret"
+	}
+
+	noAuth := []byte{version, 1, methodNoAuth}
+	passwordAuth := []byte{version, 2, methodNoAuth, methodPassword}
+	login := func(username, password string) []byte {
+		out := append([]byte{authVersion, byte(len(username))}, username...)
+		out = append(out, byte(len(password)))
+		return append(out, password...)
+	}
+	connectIPv4 := []byte{version, commandConnect, 0, addrIPv4, 192, 0, 2, 1, 0x01, 0xbb}
+
+	succeeded := []byte{version, replySucceeded, 0, addrIPv4, 0, 0, 0, 0, 0, 0}
+
+	tests := []struct {
+		name       string
+		check      Authenticator
+		data       []byte
+		wantTarget string
+		wantUser   string
+		// wantReply — все байты, отправленные сервером клиенту
+		wantReply []byte
+		wantErr   error
+	}{
+		{
+			name:       "no auth with IPv4 address",
+			data:       join(noAuth, connectIPv4),
+			wantTarget: "192.0.2.1:443",
+			wantReply:  join([]byte{version, methodNoAuth}, succeeded),
+		},
+		{
+			name: "IPv6 address",
+			data: join(noAuth, []byte{version, commandConnect, 0, addrIPv6},
+				net.ParseIP("2001:db8::1"), []byte{0, 80}),
+			wantTarget: "[2001:db8::1]:80",
+			wantReply:  join([]byte{version, methodNoAuth}, succeeded),
+		},
+		{
+			name:       "domain address",
+			data:       join(noAuth, []byte{version, commandConnect, 0, addrDomain, 11}, []byte("example.com"), []byte{0x1f, 0x90}),
+			wantTarget: "example.com:8080",
+			wantReply:  join([]byte{version, methodNoAuth}, succeeded),
+		},
+		{
+			name:       "password auth",
+			check:      check,
+			data:       join(passwordAuth, login("user", "secret"), connectIPv4),
+			wantTarget: "192.0.2.1:443",
+			wantUser:   "user",
+			wantReply:  join([]byte{version, methodPassword}, []byte{authVersion, 0x00}, succeeded),
+		},
+		{
+			name:      "wrong password",
+			check:     check,
+			data:      join(passwordAuth, login("user", "wrong"), connectIPv4),
+			wantReply: []byte{version, methodPassword, authVersion, 0x01},
+			wantErr:   ErrAuthFailed,
+		},
+		{
+			name:      "client without password method",
+			check:     check,
+			data:      join(noAuth, connectIPv4),
+			wantReply: []byte{version, methodNoAcceptable},
+		},
+		{
+			name:      "unsupported command",
+			data:      join(noAuth, []byte{version, 0x02, 0, addrIPv4, 192, 0, 2, 1, 0, 80}),
+			wantReply: join([]byte{version, methodNoAuth}, []byte{version, replyCommandNotSupported, 0, addrIPv4, 0, 0, 0, 0, 0, 0}),
+		},
+		{
+			name:      "unsupported address type",
+			data:      join(noAuth, []byte{version, commandConnect, 0, 0x05}),
+			wantReply: join([]byte{version, methodNoAuth}, []byte{version, replyAddressTypeNotSupported, 0, addrIPv4, 0, 0, 0, 0, 0, 0}),
+			wantErr:   errAddressType,
+		},
+		{
+			name:      "truncated domain",
+			data:      join(noAuth, []byte{version, commandConnect, 0, addrDomain, 11}, []byte("exam")),
+			wantReply: []byte{version, methodNoAuth},
+			wantErr:   io.ErrUnexpectedEOF,
+		},
+		{
+			name: "SOCKS4 greeting",
+			data: []byte{0x04, commandConnect, 0, 80, 192, 0, 2, 1, 0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &scriptedConn{in: bytes.NewReader(tt.data)}
+			target, user, err := Handshake(conn, tt.check)
+
+			wantFailure := tt.wantTarget == ""
+			if (err != nil) != wantFailure || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if target != tt.wantTarget || user != tt.wantUser {
+				t.Errorf("target, user = %q, %q, want %q, %q", target, user, tt.wantTarget, tt.wantUser)
+			}
+			if !bytes.Equal(conn.out.Bytes(), tt.wantReply) {
+				t.Errorf("reply = % x, want % x", conn.out.Bytes(), tt.wantReply)
+			}
+		})
+	}
+}
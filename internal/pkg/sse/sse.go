@@ -0,0 +1,127 @@
+package sse
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Event — одно событие потока text/event-stream.
+type Event struct {
+	ID    string
+	Event string
+	Data  string
+	// Truncated — событие превысило лимит парсера, остаток его строк отброшен.
+	Truncated bool
+}
+
+// Parser разбирает поток Server-Sent Events по мере записи в него и вызывает
+// onEvent для каждого завершённого события. В памяти хранится не больше limit
+// байт одного события: остаток длинных строк и лишние строки data отбрасываются
+// до пустой строки, завершающей событие.
+type Parser struct {
+	onEvent func(Event)
+	limit   int64
+	line    []byte
+	// lineSize — длина текущей строки вместе с отброшенной частью
+	lineSize int
+	lastCR   bool
+	// lineCut — текущая строка обрезана по лимиту; следующие строки события пропускаются
+	lineCut bool
+	// size — сколько байт текущего события уже сохранено
+	size    int
+	current Event
+	data    []string
+}
+
+func NewParser(limit int64, onEvent func(Event)) *Parser {
+	return &Parser{onEvent: onEvent, limit: limit}
+}
+
+func (p *Parser) Write(b []byte) (int, error) {
+	n := len(b)
+	for len(b) > 0 {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			p.appendLine(b)
+			break
+		}
+		p.appendLine(b[:i])
+		p.endLine()
+		b = b[i+1:]
+	}
+	return n, nil
+}
+
+func (p *Parser) appendLine(b []byte) {
+	if len(b) == 0 {
+		return
+	}
+	p.lineSize += len(b)
+	p.lastCR = b[len(b)-1] == '\r'
+
+	if p.current.Truncated && !p.lineCut {
+		return
+	}
+
+	room := max(p.limit-int64(p.size+len(p.line)), 0)
+	if int64(len(b)) > room {
+		b = b[:room]
+		p.current.Truncated = true
+		p.lineCut = true
+	}
+	p.line = append(p.line, b...)
+}
+
+func (p *Parser) endLine() {
+	switch {
+	case p.lineSize == 0 || p.lineSize == 1 && p.lastCR:
+		p.dispatch()
+	case len(p.line) > 0:
+		p.processLine(strings.TrimSuffix(string(p.line), "\r"))
+	}
+	p.line = p.line[:0]
+	p.lineSize = 0
+	p.lastCR = false
+	p.lineCut = false
+}
+
+func (p *Parser) processLine(line string) {
+	if strings.HasPrefix(line, ":") {
+		return
+	}
+
+	field, value, _ := strings.Cut(line, ":")
+	value = strings.TrimPrefix(value, " ")
+
+	// От обрезанной строки остался только префикс поля
+	if p.lineCut && value == "" {
+		return
+	}
+
+	switch field {
+	case "data":
+		p.data = append(p.data, value)
+		p.size += len(value) + 1
+	case "event":
+		p.size += len(value) - len(p.current.Event)
+		p.current.Event = value
+	case "id":
+		p.size += len(value) - len(p.current.ID)
+		p.current.ID = value
+	}
+}
+
+func (p *Parser) dispatch() {
+	if len(p.data) == 0 && p.current.Event == "" && !p.current.Truncated {
+		p.current = Event{}
+		p.size = 0
+		return
+	}
+
+	p.current.Data = strings.Join(p.data, "\n")
+	p.onEvent(p.current)
+
+	p.current = Event{}
+	p.data = nil
+	p.size = 0
+}
@@ -0,0 +1,101 @@
+package sse
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParser(t *testing.T) {
+	tests := []struct {
+		name   string
+		limit  int64
+		chunks []string
+		want   []Event
+		// wantLine — предел буфера строки после записи всех частей
+		wantLine int
+	}{
+		{
+			name:   "events with fields",
+			limit:  1024,
+			chunks: []string{"event: update\nid: 1\ndata: a\ndata: b\n\n: comment\ndata: c\n\n"},
+			want:   []Event{{ID: "1", Event: "update", Data: "a\nb"}, {Data: "c"}},
+		},
+		{
+			name:   "CRLF and split writes",
+			limit:  1024,
+			chunks: []string{"da", "ta: he", "llo\r", "\n\r", "\n"},
+			want:   []Event{{Data: "hello"}},
+		},
+		{
+			name:   "empty event is skipped",
+			limit:  1024,
+			chunks: []string{"id: 1\n\n\n"},
+		},
+		{
+			name:   "long line is cut at limit",
+			limit:  12,
+			chunks: []string{"data: 0123456789abcdef\n\ndata: ok\n\n"},
+			want:   []Event{{Data: "012345", Truncated: true}, {Data: "ok"}},
+		},
+		{
+			name:   "lines after limit are dropped",
+			limit:  16,
+			chunks: []string{"data: aaaa\ndata: bbbb\ndata: cccc\ndata: dddd\n\n"},
+			want:   []Event{{Data: "aaaa\nbbbb", Truncated: true}},
+		},
+		{
+			name:     "unfinished line stays within limit",
+			limit:    8,
+			chunks:   []string{"data: ", strings.Repeat("x", 1000), strings.Repeat("y", 1000)},
+			wantLine: 8,
+		},
+		{
+			name:   "split lines after limit are dropped",
+			limit:  16,
+			chunks: []string{"data: aaaa\ndata: bbbb\ndata: cc", "cc\nda", "ta: dddd\n", "\n"},
+			want:   []Event{{Data: "aaaa\nbbbb", Truncated: true}},
+		},
+		{
+			name:   "truncated line does not end the event",
+			limit:  4,
+			chunks: []string{"data: a\ndata: b\n\ndata: c\n"},
+			want:   []Event{{Truncated: true}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []Event
+			parser := NewParser(tt.limit, func(event Event) {
+				got = append(got, event)
+			})
+			for _, chunk := range tt.chunks {
+				if n, err := parser.Write([]byte(chunk)); n != len(chunk) || err != nil {
+					t.Fatalf("Write = %d, %v", n, err)
+				}
+			}
+
+			if len(got) != len(tt.want) {
+				t.Fatalf("events = %+v, want %+v", got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("event %d = %+v, want %+v", i, got[i], tt.want[i])
+				}
+			}
+			if len(parser.line) > tt.wantLine && tt.wantLine > 0 {
+				t.Errorf("line buffer holds %d bytes, want at most %d", len(parser.line), tt.wantLine)
+			}
+		})
+	}
+}
+
+func TestParserEndlessDataLines(t *testing.T) {
+	parser := NewParser(64, func(Event) {})
+	for i := 0; i < 10000; i++ {
+		parser.Write([]byte("data:\n"))
+	}
+	if len(parser.data) > 64 {
+		t.Errorf("parser keeps %d data lines, want at most 64", len(parser.data))
+	}
+}
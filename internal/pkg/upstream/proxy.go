@@ -0,0 +1,137 @@
+package upstream
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	"golang.org/x/net/proxy"
+)
+
+// parseProxyURL проверяет адрес вышестоящего прокси: http://[user:pass@]host:port
+// или socks5://[user:pass@]host:port.
+func parseProxyURL(rawURL string) (*url.URL, error) {
+	if rawURL == "" {
+		return nil, nil
+	}
+
+	proxyURL, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upstream proxy URL: %w", err)
+	}
+
+	switch proxyURL.Scheme {
+	case "http", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("unsupported upstream proxy scheme %q", proxyURL.Scheme)
+	}
+
+	if proxyURL.Port() == "" {
+		return nil, fmt.Errorf("upstream proxy URL must contain a port")
+	}
+
+	return proxyURL, nil
+}
+
+// proxyFor возвращает вышестоящий прокси для запроса или nil для прямого соединения.
+func (t *Transport) proxyFor(req *http.Request) (*url.URL, error) {
+	if t.proxyURL == nil || t.bypass.Match(req.URL.Hostname()) {
+		return nil, nil
+	}
+	return t.proxyURL, nil
+}
+
+// DialContext открывает TCP-соединение с addr напрямую или через вышестоящий прокси.
+// Используется для соединений, которые передаются без разбора HTTP.
+func (t *Transport) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+
+	if t.proxyURL == nil || t.bypass.Match(host) {
+		return t.dialDirect(ctx, network, addr)
+	}
+
+	if t.proxyURL.Scheme == "http" {
+		return t.dialConnect(ctx, network, addr)
+	}
+
+	dialer, err := proxy.FromURL(t.proxyURL, directDialer{t})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create SOCKS5 dialer: %w", err)
+	}
+	return dialer.(proxy.ContextDialer).DialContext(ctx, network, addr)
+}
+
+// dialConnect открывает туннель к addr через HTTP-прокси методом CONNECT.
+func (t *Transport) dialConnect(ctx context.Context, network, addr string) (net.Conn, error) {
+	conn, err := t.dialDirect(ctx, network, t.proxyURL.Host)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: addr},
+		Host:   addr,
+		Header: make(http.Header),
+	}
+	if user := t.proxyURL.User; user != nil {
+		password, _ := user.Password()
+		credentials := base64.StdEncoding.EncodeToString([]byte(user.Username() + ":" + password))
+		request.Header.Set("Proxy-Authorization", "Basic "+credentials)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+		defer conn.SetDeadline(time.Time{})
+	}
+
+	if err := request.Write(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to send CONNECT to upstream proxy: %w", err)
+	}
+
+	// Ответ читается по байту, чтобы не забрать из соединения данные самого туннеля
+	response, err := http.ReadResponse(bufio.NewReader(byteReader{conn}), request)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read CONNECT response from upstream proxy: %w", err)
+	}
+	if response.StatusCode != http.StatusOK {
+		conn.Close()
+		return nil, fmt.Errorf("upstream proxy refused CONNECT to %s: %s", addr, response.Status)
+	}
+
+	return conn, nil
+}
+
+type byteReader struct {
+	conn net.Conn
+}
+
+func (r byteReader) Read(p []byte) (int, error) {
+	if len(p) > 1 {
+		p = p[:1]
+	}
+	return r.conn.Read(p)
+}
+
+// directDialer — прямые соединения для SOCKS5-клиента из x/net/proxy.
+type directDialer struct {
+	t *Transport
+}
+
+func (d directDialer) Dial(network, addr string) (net.Conn, error) {
+	return d.t.dialDirect(context.Background(), network, addr)
+}
+
+func (d directDialer) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	return d.t.dialDirect(ctx, network, addr)
+}
@@ -0,0 +1,89 @@
+package upstream
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+
+	"github.com/bocharovatd/mitm-proxy/internal/config"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/hostmatch"
+)
+
+// tlsRule — отдельный пул соединений с собственными настройками TLS для хостов из списка.
+type tlsRule struct {
+	hosts     *hostmatch.List
+	transport *http.Transport
+}
+
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+func newTLSConfig(rule config.UpstreamTLSRule) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: rule.InsecureSkipVerify,
+	}
+
+	if rule.CAFile != "" {
+		caPEM, err := os.ReadFile(rule.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caPEM) {
+			return nil, fmt.Errorf("no certificates found in %s", rule.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if rule.ClientCertFile != "" || rule.ClientKeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(rule.ClientCertFile, rule.ClientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	var err error
+	if tlsConfig.MinVersion, err = parseVersion(rule.MinVersion); err != nil {
+		return nil, err
+	}
+	if tlsConfig.MaxVersion, err = parseVersion(rule.MaxVersion); err != nil {
+		return nil, err
+	}
+
+	for _, name := range rule.CipherSuites {
+		id, ok := cipherSuiteID(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		}
+		tlsConfig.CipherSuites = append(tlsConfig.CipherSuites, id)
+	}
+
+	return tlsConfig, nil
+}
+
+func parseVersion(version string) (uint16, error) {
+	if version == "" {
+		return 0, nil
+	}
+	v, ok := tlsVersions[version]
+	if !ok {
+		return 0, fmt.Errorf("unknown TLS version %q", version)
+	}
+	return v, nil
+}
+
+func cipherSuiteID(name string) (uint16, bool) {
+	for _, suite := range append(tls.CipherSuites(), tls.InsecureCipherSuites()...) {
+		if suite.Name == name {
+			return suite.ID, true
+		}
+	}
+	return 0, false
+}
@@ -0,0 +1,81 @@
+package upstream
+
+import (
+	"crypto/tls"
+	"net/http"
+	"testing"
+
+	"github.com/bocharovatd/mitm-proxy/internal/config"
+)
+
+func TestNewTLSConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    config.UpstreamTLSRule
+		want    *tls.Config
+		wantErr bool
+	}{
+		{name: "defaults", want: &tls.Config{}},
+		{
+			name: "versions and cipher suites",
+			rule: config.UpstreamTLSRule{MinVersion: "1.0", MaxVersion: "1.2", CipherSuites: []string{"TLS_RSA_WITH_AES_128_CBC_SHA", "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256"}},
+			want: &tls.Config{MinVersion: tls.VersionTLS10, MaxVersion: tls.VersionTLS12, CipherSuites: []uint16{tls.TLS_RSA_WITH_AES_128_CBC_SHA, tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}},
+		},
+		{name: "skip verification", rule: config.UpstreamTLSRule{InsecureSkipVerify: true}, want: &tls.Config{InsecureSkipVerify: true}},
+		{name: "unknown version", rule: config.UpstreamTLSRule{MinVersion: "1.4"}, wantErr: true},
+		{name: "unknown cipher suite", rule: config.UpstreamTLSRule{CipherSuites: []string{"TLS_NULL"}}, wantErr: true},
+		{name: "missing CA file", rule: config.UpstreamTLSRule{CAFile: "/nonexistent/ca.pem"}, wantErr: true},
+		{name: "client key without certificate", rule: config.UpstreamTLSRule{ClientKeyFile: "/nonexistent/client.key"}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := newTLSConfig(tt.rule)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error = %v, want error %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			if got.MinVersion != tt.want.MinVersion || got.MaxVersion != tt.want.MaxVersion || got.InsecureSkipVerify != tt.want.InsecureSkipVerify {
+				t.Errorf("config = %+v, want %+v", got, tt.want)
+			}
+			if len(got.CipherSuites) != len(tt.want.CipherSuites) {
+				t.Fatalf("cipher suites = %v, want %v", got.CipherSuites, tt.want.CipherSuites)
+			}
+			for i := range got.CipherSuites {
+				if got.CipherSuites[i] != tt.want.CipherSuites[i] {
+					t.Errorf("cipher suite %d = %#x, want %#x", i, got.CipherSuites[i], tt.want.CipherSuites[i])
+				}
+			}
+		})
+	}
+}
+
+func TestTransportFor(t *testing.T) {
+	transport, err := New(config.UpstreamConfig{TLSRules: []config.UpstreamTLSRule{
+		{Hosts: []string{"*.internal.example.com"}, InsecureSkipVerify: true},
+		{Hosts: []string{"*.example.com"}, MinVersion: "1.3"},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		host string
+		want *http.Transport
+	}{
+		{host: "api.internal.example.com", want: transport.rules[0].transport},
+		{host: "api.example.com", want: transport.rules[1].transport},
+		{host: "example.org", want: transport.transport},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host, func(t *testing.T) {
+			if got := transport.transportFor(tt.host); got != tt.want {
+				t.Errorf("transportFor(%q) picked another pool", tt.host)
+			}
+		})
+	}
+}
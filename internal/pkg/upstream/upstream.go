@@ -0,0 +1,169 @@
+package upstream
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/bocharovatd/mitm-proxy/internal/config"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/hostmatch"
+)
+
+var (
+	stats           = expvar.NewMap("upstream_pool")
+	openConnections = new(expvar.Int)
+)
+
+func init() {
+	stats.Set("open_connections", openConnections)
+}
+
+// Transport — общий пул соединений к целевым серверам с keep-alive. Для хостов
+// из правил TLS используются отдельные пулы с их настройками. Если задан вышестоящий
+// прокси, все соединения, кроме хостов из списка исключений, идут через него.
+type Transport struct {
+	transport *http.Transport
+	rules     []tlsRule
+	dialer    *net.Dialer
+	proxyURL  *url.URL
+	bypass    *hostmatch.List
+}
+
+func New(cfg config.UpstreamConfig) (*Transport, error) {
+	proxyURL, err := parseProxyURL(cfg.Proxy)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Transport{
+		dialer: &net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		},
+		proxyURL: proxyURL,
+		bypass:   hostmatch.New(cfg.ProxyBypass),
+	}
+
+	t.transport = &http.Transport{
+		Proxy:       t.proxyFor,
+		DialContext: t.dialDirect,
+		// С собственным DialContext HTTP/2 к целевым серверам нужно включать явно
+		ForceAttemptHTTP2:   true,
+		MaxIdleConns:        cfg.MaxIdleConns,
+		MaxIdleConnsPerHost: cfg.MaxIdleConnsPerHost,
+		MaxConnsPerHost:     cfg.MaxConnsPerHost,
+		IdleConnTimeout:     cfg.IdleConnTimeout,
+		TLSHandshakeTimeout: 10 * time.Second,
+		// Тело ответа передаётся клиенту без изменений, поэтому транспорт
+		// не должен сам запрашивать и распаковывать gzip
+		DisableCompression: true,
+	}
+
+	for i, rule := range cfg.TLSRules {
+		tlsConfig, err := newTLSConfig(rule)
+		if err != nil {
+			return nil, fmt.Errorf("invalid upstream TLS rule %d: %w", i+1, err)
+		}
+
+		transport := t.transport.Clone()
+		transport.TLSClientConfig = tlsConfig
+		t.rules = append(t.rules, tlsRule{hosts: hostmatch.New(rule.Hosts), transport: transport})
+	}
+
+	return t, nil
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.RoundTripTo(req, "")
+}
+
+// RoundTripTo отправляет запрос, открывая новое соединение с addr вместо адреса
+// из req.URL.Host. Имя из URL по-прежнему задаёт SNI, проверку сертификата и пул
+// соединений, поэтому соединение, открытое к addr, может быть использовано
+// повторно для того же имени. Пустой addr — соединение по req.URL.Host.
+func (t *Transport) RoundTripTo(req *http.Request, addr string) (*http.Response, error) {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			if info.Reused {
+				stats.Add("reused", 1)
+			} else {
+				stats.Add("new", 1)
+			}
+		},
+	}
+
+	ctx := httptrace.WithClientTrace(req.Context(), trace)
+	if addr != "" && addr != req.URL.Host {
+		ctx = context.WithValue(ctx, dialAddressKey{}, dialAddress{host: req.URL.Host, addr: addr})
+	}
+
+	stats.Add("requests", 1)
+	return t.transportFor(req.URL.Hostname()).RoundTrip(req.WithContext(ctx))
+}
+
+type dialAddressKey struct{}
+
+// dialAddress заменяет адрес соединения host на addr. Соединения с вышестоящим
+// прокси открываются по его собственному адресу и не затрагиваются.
+type dialAddress struct {
+	host string
+	addr string
+}
+
+func (t *Transport) CloseIdleConnections() {
+	t.transport.CloseIdleConnections()
+	for _, rule := range t.rules {
+		rule.transport.CloseIdleConnections()
+	}
+}
+
+// dialDirect открывает соединение без вышестоящего прокси и учитывает его в статистике пула.
+func (t *Transport) dialDirect(ctx context.Context, network, addr string) (net.Conn, error) {
+	if override, ok := ctx.Value(dialAddressKey{}).(dialAddress); ok && override.host == addr {
+		addr = override.addr
+	}
+
+	conn, err := t.dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		stats.Add("dial_errors", 1)
+		return nil, err
+	}
+	stats.Add("dials", 1)
+	openConnections.Add(1)
+	return &countedConn{Conn: conn}, nil
+}
+
+func (t *Transport) transportFor(host string) *http.Transport {
+	for _, rule := range t.rules {
+		if rule.hosts.Match(host) {
+			return rule.transport
+		}
+	}
+	return t.transport
+}
+
+type countedConn struct {
+	net.Conn
+	once sync.Once
+}
+
+func (c *countedConn) Close() error {
+	c.once.Do(func() {
+		openConnections.Add(-1)
+	})
+	return c.Conn.Close()
+}
+
+// CloseWrite нужен туннелям без расшифровки для передачи половинного закрытия.
+func (c *countedConn) CloseWrite() error {
+	if closer, ok := c.Conn.(interface{ CloseWrite() error }); ok {
+		return closer.CloseWrite()
+	}
+	return nil
+}
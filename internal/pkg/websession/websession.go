@@ -0,0 +1,37 @@
+// Package websession передаёт данные сессии веб-интерфейса от проверки доступа
+// к обработчикам: имя пользователя и CSRF-токен, который выводится в формы.
+package websession
+
+import (
+	"context"
+	"net/http"
+)
+
+const (
+	// CSRFField — имя скрытого поля формы с токеном.
+	CSRFField = "csrf_token"
+	// CSRFHeader — заголовок с токеном для запросов не из форм.
+	CSRFHeader = "X-CSRF-Token"
+)
+
+type contextKey struct{}
+
+type session struct {
+	user      string
+	csrfToken string
+}
+
+// WithSession сохраняет в контекст пользователя (пустой, если вход не требуется) и CSRF-токен.
+func WithSession(ctx context.Context, user, csrfToken string) context.Context {
+	return context.WithValue(ctx, contextKey{}, session{user: user, csrfToken: csrfToken})
+}
+
+func User(r *http.Request) string {
+	s, _ := r.Context().Value(contextKey{}).(session)
+	return s.user
+}
+
+func CSRFToken(r *http.Request) string {
+	s, _ := r.Context().Value(contextKey{}).(session)
+	return s.csrfToken
+}
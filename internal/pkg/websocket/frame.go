@@ -0,0 +1,141 @@
+package websocket
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	OpContinuation = 0x0
+	OpText         = 0x1
+	OpBinary       = 0x2
+	OpClose        = 0x8
+	OpPing         = 0x9
+	OpPong         = 0xA
+)
+
+// MaxFramePayload ограничивает размер одного кадра, который прокси держит в памяти.
+const MaxFramePayload = 32 << 20
+
+var ErrFrameTooLarge = errors.New("websocket frame is too large")
+
+type Frame struct {
+	Fin     bool
+	RSV     byte
+	Opcode  byte
+	Masked  bool
+	MaskKey [4]byte
+	// Payload хранится в открытом виде, маска применяется при записи.
+	Payload []byte
+}
+
+func (f *Frame) IsControl() bool {
+	return f.Opcode >= OpClose
+}
+
+func ReadFrame(r io.Reader) (*Frame, error) {
+	var header [2]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		return nil, err
+	}
+
+	frame := &Frame{
+		Fin:    header[0]&0x80 != 0,
+		RSV:    (header[0] >> 4) & 0x7,
+		Opcode: header[0] & 0x0F,
+		Masked: header[1]&0x80 != 0,
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err := io.ReadFull(r, ext[:]); err != nil {
+			return nil, err
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if length > MaxFramePayload {
+		return nil, ErrFrameTooLarge
+	}
+
+	if frame.Masked {
+		if _, err := io.ReadFull(r, frame.MaskKey[:]); err != nil {
+			return nil, err
+		}
+	}
+
+	frame.Payload = make([]byte, length)
+	if _, err := io.ReadFull(r, frame.Payload); err != nil {
+		return nil, err
+	}
+
+	if frame.Masked {
+		applyMask(frame.Payload, frame.MaskKey)
+	}
+
+	return frame, nil
+}
+
+func WriteFrame(w io.Writer, frame *Frame) error {
+	header := make([]byte, 0, 14)
+
+	b0 := frame.RSV<<4 | frame.Opcode&0x0F
+	if frame.Fin {
+		b0 |= 0x80
+	}
+	header = append(header, b0)
+
+	var b1 byte
+	if frame.Masked {
+		b1 = 0x80
+	}
+
+	length := len(frame.Payload)
+	switch {
+	case length < 126:
+		header = append(header, b1|byte(length))
+	case length <= 0xFFFF:
+		header = append(header, b1|126)
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header = append(header, b1|127)
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	payload := frame.Payload
+	if frame.Masked {
+		header = append(header, frame.MaskKey[:]...)
+		payload = make([]byte, length)
+		copy(payload, frame.Payload)
+		applyMask(payload, frame.MaskKey)
+	}
+
+	if _, err := w.Write(append(header, payload...)); err != nil {
+		return fmt.Errorf("failed to write frame: %w", err)
+	}
+	return nil
+}
+
+// NewMaskKey возвращает случайную маску для кадров, отправляемых клиентом.
+func NewMaskKey() [4]byte {
+	var key [4]byte
+	rand.Read(key[:])
+	return key
+}
+
+func applyMask(payload []byte, key [4]byte) {
+	for i := range payload {
+		payload[i] ^= key[i%4]
+	}
+}
@@ -0,0 +1,157 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+	"testing"
+)
+
+func header(b0 byte, length uint64, masked bool) []byte {
+	var mask byte
+	if masked {
+		mask = 0x80
+	}
+	switch {
+	case length < 126:
+		return []byte{b0, mask | byte(length)}
+	case length <= 0xFFFF:
+		return binary.BigEndian.AppendUint16([]byte{b0, mask | 126}, uint16(length))
+	default:
+		return binary.BigEndian.AppendUint64([]byte{b0, mask | 127}, length)
+	}
+}
+
+func TestReadFrame(t *testing.T) {
+	medium := bytes.Repeat([]byte("m"), 300)
+	large := bytes.Repeat([]byte("l"), 70000)
+
+	tests := []struct {
+		name    string
+		data    []byte
+		want    *Frame
+		wantErr error
+	}{
+		{
+			name: "unmasked text",
+			data: []byte{0x81, 0x05, 'H', 'e', 'l', 'l', 'o'},
+			want: &Frame{Fin: true, Opcode: OpText, Payload: []byte("Hello")},
+		},
+		{
+			// Пример из RFC 6455, раздел 5.7
+			name: "masked text",
+			data: []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+			want: &Frame{Fin: true, Opcode: OpText, Masked: true, MaskKey: [4]byte{0x37, 0xfa, 0x21, 0x3d}, Payload: []byte("Hello")},
+		},
+		{
+			name: "16-bit length",
+			data: append(header(0x82, uint64(len(medium)), false), medium...),
+			want: &Frame{Fin: true, Opcode: OpBinary, Payload: medium},
+		},
+		{
+			name: "64-bit length",
+			data: append(header(0x02, uint64(len(large)), false), large...),
+			want: &Frame{Opcode: OpBinary, Payload: large},
+		},
+		{
+			name: "reserved bits",
+			data: []byte{0xC1, 0x00},
+			want: &Frame{Fin: true, RSV: 0x4, Opcode: OpText, Payload: []byte{}},
+		},
+		{
+			name:    "oversized frame",
+			data:    header(0x82, MaxFramePayload+1, false),
+			wantErr: ErrFrameTooLarge,
+		},
+		{
+			name:    "oversized 64-bit length",
+			data:    header(0x82, 1<<63, true),
+			wantErr: ErrFrameTooLarge,
+		},
+		{
+			name:    "truncated extended length",
+			data:    []byte{0x82, 127, 0, 0},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated mask key",
+			data:    []byte{0x81, 0x85, 0x37, 0xfa},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "truncated payload",
+			data:    []byte{0x81, 0x05, 'H', 'e'},
+			wantErr: io.ErrUnexpectedEOF,
+		},
+		{
+			name:    "empty input",
+			wantErr: io.EOF,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			frame, err := ReadFrame(bytes.NewReader(tt.data))
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if tt.want == nil {
+				return
+			}
+
+			if frame.Fin != tt.want.Fin || frame.RSV != tt.want.RSV || frame.Opcode != tt.want.Opcode ||
+				frame.Masked != tt.want.Masked || frame.MaskKey != tt.want.MaskKey {
+				t.Errorf("frame = %+v, want %+v", frame, tt.want)
+			}
+			if !bytes.Equal(frame.Payload, tt.want.Payload) {
+				t.Errorf("payload = %d bytes, want %d", len(frame.Payload), len(tt.want.Payload))
+			}
+		})
+	}
+}
+
+func TestWriteFrame(t *testing.T) {
+	medium := bytes.Repeat([]byte("m"), 300)
+	large := bytes.Repeat([]byte("l"), 70000)
+
+	tests := []struct {
+		name  string
+		frame *Frame
+		want  []byte
+	}{
+		{
+			name:  "masked text",
+			frame: &Frame{Fin: true, Opcode: OpText, Masked: true, MaskKey: [4]byte{0x37, 0xfa, 0x21, 0x3d}, Payload: []byte("Hello")},
+			want:  []byte{0x81, 0x85, 0x37, 0xfa, 0x21, 0x3d, 0x7f, 0x9f, 0x4d, 0x51, 0x58},
+		},
+		{
+			name:  "16-bit length",
+			frame: &Frame{Fin: true, Opcode: OpBinary, Payload: medium},
+			want:  append(header(0x82, uint64(len(medium)), false), medium...),
+		},
+		{
+			name:  "64-bit length",
+			frame: &Frame{Opcode: OpBinary, Payload: large},
+			want:  append(header(0x02, uint64(len(large)), false), large...),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload := append([]byte{}, tt.frame.Payload...)
+
+			var buf bytes.Buffer
+			if err := WriteFrame(&buf, tt.frame); err != nil {
+				t.Fatalf("WriteFrame: %v", err)
+			}
+			if !bytes.Equal(buf.Bytes(), tt.want) {
+				t.Errorf("encoded %d bytes % x..., want % x...", buf.Len(), buf.Bytes()[:min(buf.Len(), 16)], tt.want[:min(len(tt.want), 16)])
+			}
+			// Маска накладывается на копию, исходные данные кадра не меняются
+			if !bytes.Equal(tt.frame.Payload, payload) {
+				t.Errorf("WriteFrame modified frame payload")
+			}
+		})
+	}
+}
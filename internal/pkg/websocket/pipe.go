@@ -0,0 +1,120 @@
+package websocket
+
+import (
+	"errors"
+	"io"
+)
+
+// MaxMessagePayload ограничивает размер сообщения, собираемого из фрагментов целиком.
+const MaxMessagePayload = 64 << 20
+
+var ErrMessageTooLarge = errors.New("websocket message is too large")
+
+// Message — сообщение, собранное из одного или нескольких кадров.
+type Message struct {
+	Opcode  byte
+	Payload []byte
+	// Truncated — в Payload попала только часть сообщения.
+	Truncated bool
+}
+
+// Pipe пересылает кадры из src в dst до кадра закрытия или ошибки. Управляющие
+// кадры пересылаются сразу, mask задаёт, маскировать ли кадры (направление
+// клиент -> сервер).
+//
+// Если rewrite установлен, текстовые и бинарные сообщения собираются из фрагментов
+// (не больше MaxMessagePayload, иначе ErrMessageTooLarge), передаются в onMessage,
+// который может изменить Payload, и отправляются одним кадром. Иначе фрагменты
+// пересылаются по мере прихода, а onMessage получает первые captureLimit байт
+// сообщения после последнего фрагмента.
+func Pipe(dst io.Writer, src io.Reader, mask, rewrite bool, captureLimit int64, onMessage func(*Message)) error {
+	var current *Message
+	var size int64
+
+	for {
+		frame, err := ReadFrame(src)
+		if err != nil {
+			return err
+		}
+
+		if frame.IsControl() {
+			if err := WriteMessage(dst, frame.Opcode, frame.Payload, mask); err != nil {
+				return err
+			}
+			if frame.Opcode == OpClose {
+				return nil
+			}
+			continue
+		}
+
+		if frame.Opcode != OpContinuation || current == nil {
+			current = &Message{Opcode: frame.Opcode}
+			size = 0
+		}
+		size += int64(len(frame.Payload))
+
+		if rewrite {
+			if size > MaxMessagePayload {
+				return ErrMessageTooLarge
+			}
+			current.Payload = append(current.Payload, frame.Payload...)
+		} else {
+			if err := writeFragment(dst, frame, mask); err != nil {
+				return err
+			}
+			current.Payload = appendLimited(current.Payload, frame.Payload, captureLimit)
+			current.Truncated = size > captureLimit
+		}
+
+		if !frame.Fin {
+			continue
+		}
+
+		onMessage(current)
+		if rewrite {
+			if err := WriteMessage(dst, current.Opcode, current.Payload, mask); err != nil {
+				return err
+			}
+		}
+		current = nil
+	}
+}
+
+// writeFragment пересылает кадр данных как есть, заново маскируя его для dst.
+func writeFragment(dst io.Writer, frame *Frame, mask bool) error {
+	fragment := &Frame{
+		Fin:     frame.Fin,
+		RSV:     frame.RSV,
+		Opcode:  frame.Opcode,
+		Masked:  mask,
+		Payload: frame.Payload,
+	}
+	if mask {
+		fragment.MaskKey = NewMaskKey()
+	}
+	return WriteFrame(dst, fragment)
+}
+
+func appendLimited(buf, payload []byte, limit int64) []byte {
+	if free := limit - int64(len(buf)); free < int64(len(payload)) {
+		if free <= 0 {
+			return buf
+		}
+		payload = payload[:free]
+	}
+	return append(buf, payload...)
+}
+
+// WriteMessage отправляет сообщение одним кадром.
+func WriteMessage(w io.Writer, opcode byte, payload []byte, mask bool) error {
+	frame := &Frame{
+		Fin:     true,
+		Opcode:  opcode,
+		Masked:  mask,
+		Payload: payload,
+	}
+	if mask {
+		frame.MaskKey = NewMaskKey()
+	}
+	return WriteFrame(w, frame)
+}
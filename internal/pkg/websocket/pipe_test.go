@@ -0,0 +1,152 @@
+package websocket
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+)
+
+func writeFrames(t *testing.T, frames ...*Frame) *bytes.Buffer {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, frame := range frames {
+		if err := WriteFrame(&buf, frame); err != nil {
+			t.Fatalf("WriteFrame: %v", err)
+		}
+	}
+	return &buf
+}
+
+func readFrames(t *testing.T, r io.Reader) []*Frame {
+	t.Helper()
+	var frames []*Frame
+	for {
+		frame, err := ReadFrame(r)
+		if err == io.EOF {
+			return frames
+		}
+		if err != nil {
+			t.Fatalf("ReadFrame: %v", err)
+		}
+		frames = append(frames, frame)
+	}
+}
+
+func TestPipe(t *testing.T) {
+	fragmented := []*Frame{
+		{Fin: false, Opcode: OpText, Masked: true, MaskKey: [4]byte{1, 2, 3, 4}, Payload: []byte("hel")},
+		{Fin: true, Opcode: OpPing, Masked: true, MaskKey: [4]byte{5, 6, 7, 8}, Payload: []byte("p")},
+		{Fin: true, Opcode: OpContinuation, Masked: true, MaskKey: [4]byte{9, 9, 9, 9}, Payload: []byte("lo")},
+		{Fin: true, Opcode: OpClose, Masked: true},
+	}
+
+	tests := []struct {
+		name         string
+		rewrite      bool
+		captureLimit int64
+		wantMessage  string
+		wantTrunc    bool
+		// wantFrames — opcode, FIN и данные кадров на выходе
+		wantFrames []string
+	}{
+		{
+			name:         "fragments forwarded as they arrive",
+			captureLimit: 1 << 20,
+			wantMessage:  "hello",
+			wantFrames:   []string{"1 false hel", "9 true p", "0 true lo", "8 true "},
+		},
+		{
+			name:         "capture limit truncates recorded payload only",
+			captureLimit: 4,
+			wantMessage:  "hell",
+			wantTrunc:    true,
+			wantFrames:   []string{"1 false hel", "9 true p", "0 true lo", "8 true "},
+		},
+		{
+			name:        "rewrite reassembles and sends one frame",
+			rewrite:     true,
+			wantMessage: "HELLO",
+			wantFrames:  []string{"9 true p", "1 true HELLO", "8 true "},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			var got *Message
+			err := Pipe(&out, writeFrames(t, fragmented...), false, tt.rewrite, tt.captureLimit, func(message *Message) {
+				if tt.rewrite {
+					message.Payload = bytes.ToUpper(message.Payload)
+				}
+				got = message
+			})
+			if err != nil {
+				t.Fatalf("Pipe: %v", err)
+			}
+
+			if got == nil || string(got.Payload) != tt.wantMessage || got.Truncated != tt.wantTrunc {
+				t.Fatalf("message = %+v, want %q truncated=%v", got, tt.wantMessage, tt.wantTrunc)
+			}
+
+			var frames []string
+			for _, frame := range readFrames(t, &out) {
+				if frame.Masked {
+					t.Errorf("frame to client is masked")
+				}
+				frames = append(frames, fmt.Sprintf("%d %v %s", frame.Opcode, frame.Fin, frame.Payload))
+			}
+			if strings.Join(frames, "|") != strings.Join(tt.wantFrames, "|") {
+				t.Errorf("frames = %q, want %q", frames, tt.wantFrames)
+			}
+		})
+	}
+}
+
+// zeros — поток нулевых байт, чтобы не держать огромные кадры во входном буфере.
+type zeros struct{}
+
+func (zeros) Read(p []byte) (int, error) {
+	clear(p)
+	return len(p), nil
+}
+
+func fragmentStream(count int, size uint64) io.Reader {
+	var readers []io.Reader
+	for i := 0; i < count; i++ {
+		header := []byte{OpText, 127}
+		if i > 0 {
+			header[0] = OpContinuation
+		}
+		header = binary.BigEndian.AppendUint64(header, size)
+		readers = append(readers, bytes.NewReader(header), io.LimitReader(zeros{}, int64(size)))
+	}
+	return io.MultiReader(readers...)
+}
+
+func TestPipeMessageLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		rewrite bool
+		wantErr error
+	}{
+		{name: "rewrite rejects oversized message", rewrite: true, wantErr: ErrMessageTooLarge},
+		// Без подмены фрагменты не накапливаются, поток просто заканчивается
+		{name: "forwarding keeps only capture limit", rewrite: false, wantErr: io.EOF},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			src := fragmentStream(3, MaxFramePayload)
+			err := Pipe(io.Discard, src, false, tt.rewrite, 16, func(*Message) {
+				t.Errorf("unfinished message passed to onMessage")
+			})
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Pipe error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -6,4 +6,7 @@ import (
 
 type Handlers interface {
 	HandleConnection(conn net.Conn)
+	HandleSOCKSConnection(conn net.Conn)
+	HandleTransparentConnection(conn net.Conn)
+	HandleReverseConnection(conn net.Conn)
 }
\ No newline at end of file
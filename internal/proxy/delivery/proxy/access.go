@@ -0,0 +1,145 @@
+package proxy
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/socks5"
+)
+
+type contextKey int
+
+const (
+	// userContextKey — имя пользователя, прошедшего Proxy-Authorization. Запросы внутри
+	// перехваченного TLS получают его из контекста запроса CONNECT.
+	userContextKey contextKey = iota
+	// serverNameContextKey — SNI клиента перехваченного TLS.
+	serverNameContextKey
+)
+
+// clientAllowed проверяет адрес клиента по спискам подсетей Deny и Allow.
+func (handlers *ProxyHandlers) clientAllowed(conn net.Conn) bool {
+	if len(handlers.cfg.Allow) == 0 && len(handlers.cfg.Deny) == 0 {
+		return true
+	}
+
+	addrPort, err := netip.ParseAddrPort(conn.RemoteAddr().String())
+	if err != nil {
+		log.Printf("Connection from %s rejected: unknown client address", conn.RemoteAddr())
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+
+	for _, prefix := range handlers.cfg.Deny {
+		if prefix.Contains(addr) {
+			log.Printf("Connection from %s rejected: address is in deny list", conn.RemoteAddr())
+			return false
+		}
+	}
+
+	if len(handlers.cfg.Allow) == 0 {
+		return true
+	}
+	for _, prefix := range handlers.cfg.Allow {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+
+	log.Printf("Connection from %s rejected: address is not in allow list", conn.RemoteAddr())
+	return false
+}
+
+// authenticate проверяет заголовок Proxy-Authorization и возвращает имя пользователя.
+// Если пользователи не настроены, аутентификация не требуется.
+func (handlers *ProxyHandlers) authenticate(request *http.Request) (string, bool) {
+	if len(handlers.cfg.Users) == 0 {
+		return "", true
+	}
+
+	user, password, ok := parseBasicAuth(request.Header.Get("Proxy-Authorization"))
+	if !ok {
+		return "", false
+	}
+
+	if !handlers.validUser(user, password) {
+		log.Printf("Proxy authentication failed for user %q from %s", user, request.RemoteAddr)
+		return "", false
+	}
+	return user, true
+}
+
+// validUser сверяет имя и пароль со списком пользователей прокси.
+func (handlers *ProxyHandlers) validUser(user, password string) bool {
+	expected, exists := handlers.cfg.Users[user]
+	match := subtle.ConstantTimeCompare([]byte(password), []byte(expected)) == 1
+	return exists && match
+}
+
+// socksAuthenticator проверяет клиентов SOCKS5 по тем же пользователям, что и
+// Proxy-Authorization, а затем по отдельной паре имени и пароля SOCKS5.
+// Если не настроено ни то ни другое, аутентификация не требуется.
+func (handlers *ProxyHandlers) socksAuthenticator() socks5.Authenticator {
+	socks := handlers.cfg.SOCKS
+	if len(handlers.cfg.Users) == 0 && socks.Username == "" {
+		return nil
+	}
+
+	return func(user, password string) bool {
+		if handlers.validUser(user, password) {
+			return true
+		}
+		if socks.Username == "" {
+			return false
+		}
+		usernameOK := subtle.ConstantTimeCompare([]byte(user), []byte(socks.Username)) == 1
+		passwordOK := subtle.ConstantTimeCompare([]byte(password), []byte(socks.Password)) == 1
+		return usernameOK && passwordOK
+	}
+}
+
+func parseBasicAuth(header string) (string, string, bool) {
+	const prefix = "Basic "
+	if len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return "", "", false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(header[len(prefix):])
+	if err != nil {
+		return "", "", false
+	}
+	return strings.Cut(string(decoded), ":")
+}
+
+func writeProxyAuthRequired(conn net.Conn) {
+	response := &http.Response{
+		StatusCode: http.StatusProxyAuthRequired,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header: http.Header{
+			"Proxy-Authenticate": {`Basic realm="mitm-proxy"`},
+		},
+		Close: true,
+	}
+	if err := response.Write(conn); err != nil {
+		log.Println("Error sending response to client:", err)
+	}
+}
+
+func withUser(request *http.Request, user string) *http.Request {
+	if user == "" {
+		return request
+	}
+	return request.WithContext(context.WithValue(request.Context(), userContextKey, user))
+}
+
+func userFromContext(ctx context.Context) string {
+	user, _ := ctx.Value(userContextKey).(string)
+	return user
+}
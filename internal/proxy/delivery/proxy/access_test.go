@@ -0,0 +1,129 @@
+package proxy
+
+import (
+	"encoding/base64"
+	"net"
+	"net/http"
+	"net/netip"
+	"testing"
+
+	"github.com/bocharovatd/mitm-proxy/internal/config"
+)
+
+// remoteConn подставляет адрес клиента.
+type remoteConn struct {
+	net.Conn
+	addr net.Addr
+}
+
+func (c remoteConn) RemoteAddr() net.Addr { return c.addr }
+
+func TestClientAllowed(t *testing.T) {
+	prefixes := func(values ...string) []netip.Prefix {
+		var result []netip.Prefix
+		for _, value := range values {
+			result = append(result, netip.MustParsePrefix(value))
+		}
+		return result
+	}
+
+	tests := []struct {
+		name  string
+		allow []netip.Prefix
+		deny  []netip.Prefix
+		addr  net.Addr
+		want  bool
+	}{
+		{name: "no lists", addr: &net.TCPAddr{IP: net.ParseIP("203.0.113.1"), Port: 1}, want: true},
+		{name: "in allow list", allow: prefixes("10.0.0.0/8"), addr: &net.TCPAddr{IP: net.ParseIP("10.1.2.3"), Port: 1}, want: true},
+		{name: "outside allow list", allow: prefixes("10.0.0.0/8"), addr: &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 1}},
+		{name: "deny wins over allow", allow: prefixes("10.0.0.0/8"), deny: prefixes("10.0.0.0/24"), addr: &net.TCPAddr{IP: net.ParseIP("10.0.0.5"), Port: 1}},
+		{name: "deny only", deny: prefixes("192.0.2.0/24"), addr: &net.TCPAddr{IP: net.ParseIP("198.51.100.1"), Port: 1}, want: true},
+		{name: "IPv4-mapped IPv6 address", allow: prefixes("10.0.0.0/8"), addr: &net.TCPAddr{IP: net.ParseIP("::ffff:10.0.0.1"), Port: 1}, want: true},
+		{name: "IPv6 address", deny: prefixes("2001:db8::/32"), addr: &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 1}},
+		{name: "unknown address", allow: prefixes("10.0.0.0/8"), addr: &net.UnixAddr{Name: "/tmp/socket", Net: "unix"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := &ProxyHandlers{cfg: config.ProxyConfig{Allow: tt.allow, Deny: tt.deny}}
+			if got := handlers.clientAllowed(remoteConn{addr: tt.addr}); got != tt.want {
+				t.Errorf("clientAllowed(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAuthenticate(t *testing.T) {
+	basic := func(credentials string) string {
+		return "Basic " + base64.StdEncoding.EncodeToString([]byte(credentials))
+	}
+	users := map[string]string{"alice": "secret"}
+
+	tests := []struct {
+		name     string
+		users    map[string]string
+		header   string
+		wantUser string
+		wantOK   bool
+	}{
+		{name: "no users configured", wantOK: true},
+		{name: "valid credentials", users: users, header: basic("alice:secret"), wantUser: "alice", wantOK: true},
+		{name: "scheme is case-insensitive", users: users, header: "basic " + basic("alice:secret")[len("Basic "):], wantUser: "alice", wantOK: true},
+		{name: "wrong password", users: users, header: basic("alice:wrong")},
+		{name: "unknown user", users: users, header: basic("bob:secret")},
+		{name: "empty password for unknown user", users: users, header: basic("bob:")},
+		{name: "missing header", users: users},
+		{name: "not base64", users: users, header: "Basic !!!"},
+		{name: "no colon", users: users, header: basic("alice")},
+		{name: "other scheme", users: users, header: "Bearer token"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := &ProxyHandlers{cfg: config.ProxyConfig{Users: tt.users}}
+			request := &http.Request{Header: http.Header{}, RemoteAddr: "192.0.2.1:1"}
+			if tt.header != "" {
+				request.Header.Set("Proxy-Authorization", tt.header)
+			}
+
+			user, ok := handlers.authenticate(request)
+			if user != tt.wantUser || ok != tt.wantOK {
+				t.Errorf("authenticate = %q, %v, want %q, %v", user, ok, tt.wantUser, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestSocksAuthenticator(t *testing.T) {
+	tests := []struct {
+		name     string
+		users    map[string]string
+		socks    config.SOCKSConfig
+		user     string
+		password string
+		// wantNil — аутентификация SOCKS5 не требуется
+		wantNil bool
+		want    bool
+	}{
+		{name: "nothing configured", wantNil: true},
+		{name: "proxy user", users: map[string]string{"alice": "secret"}, user: "alice", password: "secret", want: true},
+		{name: "SOCKS user", socks: config.SOCKSConfig{Username: "socks", Password: "pass"}, user: "socks", password: "pass", want: true},
+		{name: "SOCKS user with proxy users", users: map[string]string{"alice": "secret"}, socks: config.SOCKSConfig{Username: "socks", Password: "pass"}, user: "socks", password: "pass", want: true},
+		{name: "wrong SOCKS password", socks: config.SOCKSConfig{Username: "socks", Password: "pass"}, user: "socks", password: "wrong"},
+		{name: "unknown user", users: map[string]string{"alice": "secret"}, user: "bob", password: "secret"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handlers := &ProxyHandlers{cfg: config.ProxyConfig{Users: tt.users, SOCKS: tt.socks}}
+			check := handlers.socksAuthenticator()
+			if (check == nil) != tt.wantNil {
+				t.Fatalf("authenticator is nil: %v, want %v", check == nil, tt.wantNil)
+			}
+			if check != nil && check(tt.user, tt.password) != tt.want {
+				t.Errorf("check(%q, %q) = %v, want %v", tt.user, tt.password, !tt.want, tt.want)
+			}
+		})
+	}
+}
@@ -2,105 +2,304 @@ package proxy
 
 import (
 	"bufio"
+	"context"
 	"crypto/tls"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
+	"net/url"
+	"strings"
+	"syscall"
 	"time"
 
+	"golang.org/x/net/http2"
+
+	"github.com/bocharovatd/mitm-proxy/internal/config"
+	"github.com/bocharovatd/mitm-proxy/internal/passthrough"
+	passthroughEntity "github.com/bocharovatd/mitm-proxy/internal/passthrough/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/clienthello"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/hostmatch"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/sse"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/upstream"
+	wsframe "github.com/bocharovatd/mitm-proxy/internal/pkg/websocket"
 	"github.com/bocharovatd/mitm-proxy/internal/proxy"
 	"github.com/bocharovatd/mitm-proxy/internal/request"
 	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/websocket"
+	websocketEntity "github.com/bocharovatd/mitm-proxy/internal/websocket/entity"
+)
+
+const (
+	webSocketCloseTimeout = 5 * time.Second
+	tunnelDialTimeout     = 10 * time.Second
+	// pinningCloseWindow — закрытие соединения без данных в этот срок после
+	// рукопожатия считается отказом клиента от сертификата, а не обычным обрывом
+	pinningCloseWindow = time.Second
 )
 
 type ProxyHandlers struct {
-	usecase        proxy.Usecase
-	requestUsecase request.Usecase
+	usecase            proxy.Usecase
+	requestUsecase     request.Usecase
+	webSocketUsecase   websocket.Usecase
+	transport          *upstream.Transport
+	passthrough        *hostmatch.List
+	passthroughUsecase passthrough.Usecase
+	cfg                config.ProxyConfig
 }
 
-func NewProxyHandlers(proxyUC proxy.Usecase, requestUC request.Usecase) proxy.Handlers {
+func NewProxyHandlers(proxyUC proxy.Usecase, requestUC request.Usecase, webSocketUC websocket.Usecase, passthroughUC passthrough.Usecase,
+	transport *upstream.Transport, cfg config.ProxyConfig) proxy.Handlers {
 	return &ProxyHandlers{
-		usecase:        proxyUC,
-		requestUsecase: requestUC,
+		usecase:            proxyUC,
+		requestUsecase:     requestUC,
+		webSocketUsecase:   webSocketUC,
+		transport:          transport,
+		passthrough:        hostmatch.New(cfg.Passthrough),
+		passthroughUsecase: passthroughUC,
+		cfg:                cfg,
 	}
 }
 
 func (handlers *ProxyHandlers) HandleConnection(conn net.Conn) {
 	defer conn.Close()
 
-	request, err := http.ReadRequest(bufio.NewReader(conn))
-	if err != nil {
-		log.Println("Error reading request:", err)
+	if !handlers.clientAllowed(conn) {
 		return
 	}
 
-	if request.Method == http.MethodConnect {
-		handlers.HandleHTTPSConnection(conn, request)
-	} else {
-		handlers.HandleHTTPConnection(conn, request, nil)
+	reader := bufio.NewReader(conn)
+
+	for {
+		conn.SetReadDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+
+		request, err := http.ReadRequest(reader)
+		if err != nil {
+			if !isClosedOrIdle(err) {
+				log.Println("Error reading request:", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Time{})
+		request.RemoteAddr = conn.RemoteAddr().String()
+
+		user, ok := handlers.authenticate(request)
+		if !ok {
+			writeProxyAuthRequired(conn)
+			return
+		}
+		request.Header.Del("Proxy-Authorization")
+		request = withUser(request, user)
+
+		if request.Method == http.MethodConnect {
+			handlers.HandleHTTPSConnection(conn, request)
+			return
+		}
+
+		target := targetAddress(request.URL.Host, request.Host, "80")
+		if err := handlers.HandleHTTPConnection(conn, reader, request, "http", target); err != nil {
+			log.Println("Error handling request:", err)
+			return
+		}
+
+		if request.Close {
+			return
+		}
 	}
 }
 
-func (handlers *ProxyHandlers) HandleHTTPConnection(conn net.Conn, request *http.Request, tlsConfig *tls.Config) {
-	clientIP := conn.RemoteAddr().String()
-	httpReq := requestEntity.ParseHTTPRequest(request)
+// HandleHTTPConnection проксирует один запрос клиента. Возвращает ошибку, если
+// соединение с клиентом больше нельзя использовать для следующих запросов.
+// reader — буферизованный поток чтения из conn, из которого был прочитан запрос.
+func (handlers *ProxyHandlers) HandleHTTPConnection(conn net.Conn, reader *bufio.Reader, request *http.Request, scheme, target string) error {
+	// Закрытие соединения клиентом не должно закрывать соединение из пула
+	keepAlive := !request.Close
+	request.Close = false
 
-	var targetConn net.Conn
-	var err error
-	if tlsConfig != nil {
-		targetConn, err = tls.Dial("tcp", fmt.Sprintf("%s:%s", request.Host, "443"), tlsConfig)
-	} else {
-		targetConn, err = net.Dial("tcp", fmt.Sprintf("%s:%s", request.URL.Hostname(), "80"))
-	}
+	metadata := clientMetadata(conn, request)
+	httpReq, requestCapture := handlers.prepareRequest(request, scheme, target)
+
+	startTime := time.Now()
 
+	response, err := handlers.transport.RoundTripTo(request, target)
 	if err != nil {
-		log.Println("Error connecting to target:", err)
-		return
+		log.Println("Error sending request to target:", err)
+		handlers.saveFailedHandshake(httpReq, requestCapture, metadata, startTime, err)
+		writeBadGateway(conn)
+		return fmt.Errorf("upstream request failed: %w", err)
 	}
+	defer response.Body.Close()
 
-	defer targetConn.Close()
+	duration := time.Since(startTime)
+
+	httpResp := requestEntity.ParseHTTPResponse(response, duration)
+	metadata.UpstreamProtocol = response.Proto
+	metadata.UpstreamTLS = requestEntity.ParseTLSState(response.TLS)
+
+	if response.StatusCode == http.StatusSwitchingProtocols {
+		httpReq.SetBody(requestCapture)
+		request.Close = true
+		return handlers.relayWebSocket(conn, reader, response, httpReq, httpResp, metadata)
+	}
+
+	recording := handlers.startRecording(response, httpReq, requestCapture, httpResp, metadata)
+
+	response.Header.Del("Connection")
+	response.Header.Del("Keep-Alive")
+
+	// Длина тела неизвестна (часто у ответов по HTTP/2): клиенту HTTP/1.1 конец
+	// обозначается chunked-кодированием, иначе — закрытием соединения
+	if response.ContentLength < 0 && len(response.TransferEncoding) == 0 {
+		if keepAlive && request.ProtoAtLeast(1, 1) {
+			response.TransferEncoding = []string{"chunked"}
+		} else {
+			keepAlive = false
+		}
+	}
+	response.Close = !keepAlive
+	request.Close = !keepAlive
+
+	// Ответ целевого сервера мог прийти по HTTP/2, клиенту он отправляется как HTTP/1.1
+	response.Proto, response.ProtoMajor, response.ProtoMinor = "HTTP/1.1", 1, 1
+
+	// Тело передаётся клиенту по мере чтения: conn не буферизован, поэтому каждый
+	// прочитанный фрагмент (в том числе chunk или событие SSE) сразу уходит клиенту
+	writeErr := response.Write(conn)
+
+	recording.finish()
+
+	if writeErr != nil {
+		return fmt.Errorf("failed to send response to client: %w", writeErr)
+	}
+
+	return nil
+}
+
+// prepareRequest направляет запрос клиента на target, убирает заголовки,
+// относящиеся только к соединению с прокси, и начинает перехват тела запроса.
+// Соединение открывается с target, а в URL остаётся имя сервера (см. upstreamHost).
+func (handlers *ProxyHandlers) prepareRequest(request *http.Request, scheme, target string) (*requestEntity.HTTPRequest, *requestEntity.BodyCapture) {
+	request.URL.Scheme = scheme
+	request.URL.Host = upstreamHost(request, target)
+
+	httpReq := requestEntity.ParseHTTPRequest(request)
 
 	request.Header.Del("Proxy-Connection")
+	if requestEntity.IsWebSocketUpgrade(request) {
+		// Без permessage-deflate сообщения сохраняются в открытом виде
+		request.Header.Del("Sec-WebSocket-Extensions")
+	} else {
+		request.Header.Del("Connection")
+		request.Header.Del("Keep-Alive")
+	}
 	request.RequestURI = ""
 
-	dump, err := httputil.DumpRequest(request, true)
+	dump, err := httputil.DumpRequest(request, false)
 	if err != nil {
 		log.Println("Error dumping request:", err)
 	} else {
 		log.Printf("Target request:\n%s", dump)
 	}
 
-	startTime := time.Now()
+	var requestCapture *requestEntity.BodyCapture
+	request.Body, requestCapture = requestEntity.CaptureBody(request.Body, handlers.cfg.BodyCaptureLimit)
 
-	err = request.Write(targetConn)
-	if err != nil {
-		log.Println("Error sending request to target:", err)
-		return
+	return httpReq, requestCapture
+}
+
+// recording — сохранение одного обмена запрос/ответ в историю.
+type recording struct {
+	handlers        *ProxyHandlers
+	response        *http.Response
+	httpReq         *requestEntity.HTTPRequest
+	requestCapture  *requestEntity.BodyCapture
+	httpResp        *requestEntity.HTTPResponse
+	responseCapture *requestEntity.BodyCapture
+	metadata        requestEntity.Metadata
+	streamID        string
+	events          *streamRecorder[*requestEntity.StreamEvent]
+}
+
+// startRecording начинает перехват тела ответа. Поток событий сохраняется сразу
+// после получения заголовков, а события дописываются к записи в фоне по мере прихода.
+func (handlers *ProxyHandlers) startRecording(response *http.Response, httpReq *requestEntity.HTTPRequest, requestCapture *requestEntity.BodyCapture,
+	httpResp *requestEntity.HTTPResponse, metadata requestEntity.Metadata) *recording {
+	rec := &recording{
+		handlers:       handlers,
+		response:       response,
+		httpReq:        httpReq,
+		requestCapture: requestCapture,
+		httpResp:       httpResp,
+		metadata:       metadata,
 	}
 
-	response, err := http.ReadResponse(bufio.NewReader(targetConn), request)
-	if err != nil {
-		log.Println("Error reading response:", err)
+	response.Body, rec.responseCapture = requestEntity.CaptureBody(response.Body, handlers.cfg.BodyCaptureLimit)
+
+	if requestEntity.IsStreaming(response) {
+		httpReq.SetBody(requestCapture)
+		streamID, err := handlers.requestUsecase.Save(httpReq, httpResp, metadata)
+		if err != nil {
+			log.Printf("Failed to save request: %v", err)
+		} else {
+			rec.streamID = streamID
+			rec.events = handlers.newEventRecorder(streamID)
+			response.Body = recordEvents(response.Body, handlers.cfg.BodyCaptureLimit, rec.events)
+		}
+	}
+
+	return rec
+}
+
+// saveFailedHandshake сохраняет запрос, для которого не удалось рукопожатие TLS с
+// целевым сервером, вместе с причиной отказа. Остальные ошибки соединения не сохраняются.
+func (handlers *ProxyHandlers) saveFailedHandshake(httpReq *requestEntity.HTTPRequest, requestCapture *requestEntity.BodyCapture,
+	metadata requestEntity.Metadata, startTime time.Time, err error) {
+	metadata.UpstreamTLS = requestEntity.ParseTLSError(err)
+	if metadata.UpstreamTLS == nil {
 		return
 	}
-	defer response.Body.Close()
 
-	duration := time.Since(startTime)
+	httpReq.SetBody(requestCapture)
+	httpResp := &requestEntity.HTTPResponse{
+		Code:     http.StatusBadGateway,
+		Message:  "502 Bad Gateway",
+		Duration: time.Since(startTime),
+	}
 
-	httpResp := requestEntity.ParseHTTPResponse(response, duration)
+	if _, err := handlers.requestUsecase.Save(httpReq, httpResp, metadata); err != nil {
+		log.Printf("Failed to save request: %v", err)
+	}
+}
 
-	if _, err := handlers.requestUsecase.Save(httpReq, httpResp, clientIP); err != nil {
+// finish сохраняет перехваченные тела после того, как ответ передан клиенту.
+func (rec *recording) finish() {
+	rec.httpReq.SetBody(rec.requestCapture)
+	rec.httpResp.SetBody(rec.responseCapture)
+	rec.httpResp.SetTrailers(rec.response.Trailer)
+
+	if rec.streamID != "" {
+		rec.events.Close()
+		if err := rec.handlers.requestUsecase.UpdateResponse(rec.streamID, rec.httpResp); err != nil {
+			log.Printf("Failed to update streamed response: %v", err)
+		}
+	} else if _, err := rec.handlers.requestUsecase.Save(rec.httpReq, rec.httpResp, rec.metadata); err != nil {
 		log.Printf("Failed to save request: %v", err)
 	}
+}
 
-	err = response.Write(conn)
-	if err != nil {
-		log.Println("Error sending response to client:", err)
-		return
+func clientMetadata(conn net.Conn, request *http.Request) requestEntity.Metadata {
+	metadata := requestEntity.Metadata{
+		ClientIP: conn.RemoteAddr().String(),
+		User:     userFromContext(request.Context()),
+		Protocol: request.Proto,
 	}
+	if clientTLS, ok := conn.(interface{ ConnectionState() tls.ConnectionState }); ok {
+		metadata.SNI = clientTLS.ConnectionState().ServerName
+	}
+	return metadata
 }
 
 func (handlers *ProxyHandlers) HandleHTTPSConnection(conn net.Conn, request *http.Request) {
@@ -117,31 +316,512 @@ func (handlers *ProxyHandlers) HandleHTTPSConnection(conn net.Conn, request *htt
 		return
 	}
 
-	domain := request.URL.Hostname()
+	handlers.interceptTLS(conn, request)
+}
+
+// interceptTLS расшифровывает TLS-соединение клиента с адресом из request
+// либо передаёт его без расшифровки, если хост в списке исключений.
+func (handlers *ProxyHandlers) interceptTLS(conn net.Conn, request *http.Request) {
+	target := targetAddress(request.Host, "", "443")
+	domain, _, _ := net.SplitHostPort(target)
 
-	cert, err := handlers.usecase.GetCertificate(domain)
+	// SNI нужен до решения о перехвате: CONNECT может быть адресован по IP
+	conn.SetReadDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+	serverName, conn, err := clienthello.PeekServerName(conn)
 	if err != nil {
-		log.Printf("Failed to get certificate for %s: %v", domain, err)
+		log.Printf("Failed to read ClientHello for %s: %v", domain, err)
+	}
+	conn.SetReadDeadline(time.Time{})
+
+	// Отказы клиентов учитываются по SNI, а без него — по адресу из CONNECT
+	host := serverName
+	if host == "" {
+		host = domain
+	}
+
+	if handlers.passthrough.Match(domain) || handlers.passthrough.Match(serverName) {
+		handlers.tunnel(conn, request, target, serverName, requestEntity.Tunnel{})
+		return
+	}
+
+	if handlers.passthroughUsecase.IsPassthrough(host) {
+		handlers.tunnel(conn, request, target, serverName, requestEntity.Tunnel{Learned: true})
 		return
 	}
 
+	// Ошибка выпуска сертификата — сбой прокси, а не отказ клиента
+	certificateFailed := false
 	tlsConfig := &tls.Config{
-		Certificates: []tls.Certificate{cert},
+		GetCertificate: func(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+			host := hello.ServerName
+			if host == "" {
+				host = domain
+			}
+
+			cert, err := handlers.usecase.GetCertificate(host)
+			if err != nil {
+				log.Printf("Failed to get certificate for %s: %v", host, err)
+				certificateFailed = true
+				return nil, err
+			}
+			return &cert, nil
+		},
+	}
+
+	if handlers.cfg.HTTP2 {
+		tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
 	}
 
 	tlsConn := tls.Server(conn, tlsConfig)
 	defer tlsConn.Close()
 
+	if err := tlsConn.Handshake(); err != nil {
+		log.Printf("TLS handshake with client for %s failed: %v", domain, err)
+		if certificateFailed {
+			return
+		}
+
+		handlers.recordClientFailure(host, err)
+		return
+	}
+
+	// Клиенты с certificate pinning принимают сертификат в рукопожатии, а затем
+	// закрывают соединение, не отправив ни одного запроса. Успехом считаются
+	// только первые данные от клиента
 	reader := bufio.NewReader(tlsConn)
+	tlsConn.SetReadDeadline(time.Now().Add(pinningCloseWindow))
+	_, err = reader.Peek(1)
+	tlsConn.SetReadDeadline(time.Time{})
+
+	var netErr net.Error
+	switch {
+	case err == nil:
+		if err := handlers.passthroughUsecase.RecordSuccess(host); err != nil {
+			log.Printf("Failed to reset handshake failures: %v", err)
+		}
+	case errors.As(err, &netErr) && netErr.Timeout():
+		// Клиент открыл соединение заранее и пока не отправил запрос
+	case errors.Is(err, io.EOF) || errors.Is(err, syscall.ECONNRESET):
+		log.Printf("Client closed TLS connection for %s right after the handshake", domain)
+		handlers.recordClientFailure(host, fmt.Errorf("%w: %v", passthroughEntity.ErrClosedAfterHandshake, err))
+		return
+	default:
+		log.Printf("Error reading from client for %s: %v", domain, err)
+		handlers.recordClientFailure(host, err)
+		return
+	}
+
+	// Запросы внутри туннеля наследуют пользователя из CONNECT, а SNI задаёт
+	// имя целевого сервера, если клиент подключился по IP
+	ctx := request.Context()
+	if serverName != "" {
+		ctx = context.WithValue(ctx, serverNameContextKey, serverName)
+	}
+
+	if tlsConn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+		handlers.serveHTTP2(ctx, &peekedTLSConn{Conn: tlsConn, reader: reader}, "https", target, "")
+		return
+	}
 
 	for {
-		request, err = http.ReadRequest(reader)
+		tlsConn.SetReadDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+
+		request, err := http.ReadRequest(reader)
 		if err != nil {
-			if err != io.EOF {
+			if !isClosedOrIdle(err) {
 				log.Printf("Error reading request: %v", err)
 			}
 			return
 		}
-		handlers.HandleHTTPConnection(tlsConn, request, tlsConfig)
+		tlsConn.SetReadDeadline(time.Time{})
+		request = request.WithContext(ctx)
+
+		if err := handlers.HandleHTTPConnection(tlsConn, reader, request, "https", target); err != nil {
+			log.Printf("Error handling request: %v", err)
+			return
+		}
+
+		if request.Close {
+			return
+		}
+	}
+}
+
+// recordClientFailure учитывает отказ клиента от сертификата прокси для host.
+func (handlers *ProxyHandlers) recordClientFailure(host string, clientErr error) {
+	switched, err := handlers.passthroughUsecase.RecordFailure(host, clientErr)
+	if err != nil {
+		log.Printf("Failed to record handshake failure: %v", err)
+	} else if switched {
+		log.Printf("Host %s switched to TLS passthrough after repeated handshake failures", host)
+	}
+}
+
+// peekedTLSConn отдаёт сначала байты, уже прочитанные из TLS-соединения клиента,
+// и сохраняет доступ к его состоянию TLS (SNI, ALPN).
+type peekedTLSConn struct {
+	*tls.Conn
+	reader *bufio.Reader
+}
+
+func (c *peekedTLSConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// tunnel передаёт соединение клиента на target без расшифровки. В историю
+// сохраняется запись только с метаданными: SNI, объём данных и длительность.
+// info задаёт признаки туннеля, счётчики байт заполняются здесь.
+func (handlers *ProxyHandlers) tunnel(conn net.Conn, request *http.Request, target, serverName string, info requestEntity.Tunnel) {
+	startTime := time.Now()
+
+	ctx, cancel := context.WithTimeout(context.Background(), tunnelDialTimeout)
+	upstreamConn, err := handlers.transport.DialContext(ctx, "tcp", target)
+	cancel()
+	if err != nil {
+		log.Printf("Failed to connect to %s for tunnel: %v", target, err)
+		return
+	}
+	defer upstreamConn.Close()
+
+	info.BytesSent, info.BytesReceived = handlers.splice(conn, upstreamConn)
+
+	httpReq := requestEntity.ParseHTTPRequest(request)
+	httpReq.Scheme = "https"
+	if info.Raw {
+		httpReq.Scheme = "tcp"
+	}
+
+	httpResp := &requestEntity.HTTPResponse{
+		Code:     http.StatusOK,
+		Message:  "200 Connection Established",
+		Duration: time.Since(startTime),
+	}
+
+	metadata := requestEntity.Metadata{
+		ClientIP: conn.RemoteAddr().String(),
+		User:     userFromContext(request.Context()),
+		SNI:      serverName,
+		Protocol: request.Proto,
+		Tunnel:   &info,
+	}
+
+	if _, err := handlers.requestUsecase.Save(httpReq, httpResp, metadata); err != nil {
+		log.Printf("Failed to save tunnel: %v", err)
+	}
+}
+
+// splice копирует данные в обе стороны до закрытия соединений и возвращает число
+// байт, отправленных клиентом и полученных им. Когда одна сторона закрывает
+// соединение, вторая получает половинное закрытие и ClientIdleTimeout на завершение.
+func (handlers *ProxyHandlers) splice(client, upstream net.Conn) (int64, int64) {
+	sentCh := make(chan int64, 1)
+	go func() {
+		n, _ := io.Copy(upstream, client)
+		closeWrite(upstream)
+		upstream.SetReadDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+		sentCh <- n
+	}()
+
+	received, _ := io.Copy(client, upstream)
+	closeWrite(client)
+	client.SetReadDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+
+	return <-sentCh, received
+}
+
+func closeWrite(conn net.Conn) {
+	if closer, ok := conn.(interface{ CloseWrite() error }); ok {
+		closer.CloseWrite()
+	}
+}
+
+// relayWebSocket завершает апгрейд с клиентом и пересылает кадры в обе стороны,
+// сохраняя каждое сообщение в запись рукопожатия.
+func (handlers *ProxyHandlers) relayWebSocket(conn net.Conn, reader *bufio.Reader, response *http.Response,
+	httpReq *requestEntity.HTTPRequest, httpResp *requestEntity.HTTPResponse, metadata requestEntity.Metadata) error {
+	upstreamConn, ok := response.Body.(io.ReadWriteCloser)
+	if !ok {
+		return fmt.Errorf("upstream connection does not support upgrade")
+	}
+	defer upstreamConn.Close()
+
+	handshake := *response
+	handshake.Body = nil
+	if err := handshake.Write(conn); err != nil {
+		return fmt.Errorf("failed to send upgrade response to client: %w", err)
+	}
+
+	requestID, err := handlers.requestUsecase.Save(httpReq, httpResp, metadata)
+	if err != nil {
+		log.Printf("Failed to save request: %v", err)
+	}
+
+	var recorder *streamRecorder[*requestEntity.WebSocketMessage]
+	if requestID != "" {
+		recorder = handlers.newMessageRecorder(requestID)
+		defer recorder.Close()
+	}
+
+	rules, err := handlers.webSocketUsecase.GetRules()
+	if err != nil {
+		log.Printf("Failed to load websocket rules: %v", err)
+	}
+
+	process := func(direction string) func(*wsframe.Message) {
+		return func(message *wsframe.Message) {
+			var original []byte
+			for _, rule := range rules {
+				payload, modified := rule.Apply(direction, message.Payload)
+				if modified {
+					if original == nil {
+						original = message.Payload
+					}
+					message.Payload = payload
+				}
+			}
+
+			if recorder != nil {
+				recorder.Add(handlers.webSocketMessage(direction, message, original))
+			}
+		}
+	}
+
+	errc := make(chan error, 2)
+	go func() {
+		errc <- wsframe.Pipe(upstreamConn, reader, true, hasRules(rules, websocketEntity.DirectionClient),
+			handlers.cfg.BodyCaptureLimit, process(websocketEntity.DirectionClient))
+	}()
+	go func() {
+		errc <- wsframe.Pipe(conn, upstreamConn, false, hasRules(rules, websocketEntity.DirectionServer),
+			handlers.cfg.BodyCaptureLimit, process(websocketEntity.DirectionServer))
+	}()
+
+	err = <-errc
+
+	// После кадра закрытия ждём ответный кадр от второй стороны, иначе разблокируем её сразу
+	if err == nil {
+		select {
+		case <-errc:
+			return nil
+		case <-time.After(webSocketCloseTimeout):
+		}
+	}
+
+	upstreamConn.Close()
+	conn.SetReadDeadline(time.Now())
+	<-errc
+
+	if err != nil && !isClosedOrIdle(err) {
+		log.Printf("WebSocket relay for %s stopped: %v", httpReq.Host, err)
+	}
+	return nil
+}
+
+// hasRules сообщает, есть ли правила подмены для направления: только тогда
+// сообщения собираются из фрагментов целиком перед отправкой.
+func hasRules(rules []*websocketEntity.Rule, direction string) bool {
+	for _, rule := range rules {
+		if rule.Direction == direction || rule.Direction == websocketEntity.DirectionBoth {
+			return true
+		}
+	}
+	return false
+}
+
+func (handlers *ProxyHandlers) newMessageRecorder(requestID string) *streamRecorder[*requestEntity.WebSocketMessage] {
+	size := func(message *requestEntity.WebSocketMessage) int {
+		return len(message.Payload) + len(message.Original)
+	}
+	flush := func(messages []*requestEntity.WebSocketMessage, truncated bool) error {
+		return handlers.requestUsecase.AppendMessages(requestID, messages, truncated)
+	}
+	return newStreamRecorder(handlers.cfg.StreamRecordLimit, size, flush)
+}
+
+func (handlers *ProxyHandlers) webSocketMessage(direction string, message *wsframe.Message, original []byte) *requestEntity.WebSocketMessage {
+	payload := message.Payload
+	truncated := message.Truncated || int64(len(payload)) > handlers.cfg.BodyCaptureLimit
+	if truncated {
+		payload = payload[:handlers.cfg.BodyCaptureLimit]
+	}
+	if int64(len(original)) > handlers.cfg.BodyCaptureLimit {
+		original = original[:handlers.cfg.BodyCaptureLimit]
+	}
+
+	return &requestEntity.WebSocketMessage{
+		Timestamp: time.Now(),
+		Direction: direction,
+		Opcode:    int(message.Opcode),
+		Payload:   payload,
+		Truncated: truncated,
+		Original:  original,
+	}
+}
+
+func (handlers *ProxyHandlers) newEventRecorder(requestID string) *streamRecorder[*requestEntity.StreamEvent] {
+	size := func(event *requestEntity.StreamEvent) int {
+		return len(event.ID) + len(event.Event) + len(event.Data)
+	}
+	flush := func(events []*requestEntity.StreamEvent, truncated bool) error {
+		return handlers.requestUsecase.AppendEvents(requestID, events, truncated)
+	}
+	return newStreamRecorder(handlers.cfg.StreamRecordLimit, size, flush)
+}
+
+// recordEvents разбирает события SSE в теле ответа и передаёт каждое в recorder.
+// Каждое событие сохраняется не больше чем на limit байт.
+func recordEvents(body io.ReadCloser, limit int64, recorder *streamRecorder[*requestEntity.StreamEvent]) io.ReadCloser {
+	parser := sse.NewParser(limit, func(event sse.Event) {
+		recorder.Add(&requestEntity.StreamEvent{
+			Timestamp: time.Now(),
+			ID:        event.ID,
+			Event:     event.Event,
+			Data:      event.Data,
+			Truncated: event.Truncated,
+		})
+	})
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{Reader: io.TeeReader(body, parser), Closer: body}
+}
+
+// serveHTTP2 обслуживает h2-соединение клиента: каждый поток проксируется на
+// scheme://target и сохраняется как отдельный запрос. Непустой host заменяет
+// заголовок Host в запросах клиента. Запросы получают контекст ctx.
+func (handlers *ProxyHandlers) serveHTTP2(ctx context.Context, conn net.Conn, scheme, target, host string) {
+	server := &http2.Server{
+		IdleTimeout: handlers.cfg.ClientIdleTimeout,
+	}
+
+	server.ServeConn(conn, &http2.ServeConnOpts{
+		Context: ctx,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, request *http.Request) {
+			if host != "" {
+				request.Host = host
+			}
+			handlers.handleHTTP2Request(w, request, conn, scheme, target)
+		}),
+	})
+}
+
+func (handlers *ProxyHandlers) handleHTTP2Request(w http.ResponseWriter, request *http.Request, conn net.Conn, scheme, target string) {
+	metadata := clientMetadata(conn, request)
+	httpReq, requestCapture := handlers.prepareRequest(request, scheme, target)
+
+	startTime := time.Now()
+
+	response, err := handlers.transport.RoundTripTo(request, target)
+	if err != nil {
+		log.Println("Error sending request to target:", err)
+		handlers.saveFailedHandshake(httpReq, requestCapture, metadata, startTime, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+		return
+	}
+	defer response.Body.Close()
+
+	duration := time.Since(startTime)
+
+	httpResp := requestEntity.ParseHTTPResponse(response, duration)
+	metadata.UpstreamProtocol = response.Proto
+	metadata.UpstreamTLS = requestEntity.ParseTLSState(response.TLS)
+
+	recording := handlers.startRecording(response, httpReq, requestCapture, httpResp, metadata)
+
+	for key, values := range response.Header {
+		switch key {
+		case "Connection", "Keep-Alive", "Transfer-Encoding", "Upgrade", "Proxy-Connection":
+			continue
+		}
+		w.Header()[key] = values
+	}
+	w.WriteHeader(response.StatusCode)
+
+	if err := copyWithFlush(w, response.Body); err != nil {
+		log.Printf("Error sending response to client: %v", err)
+	}
+
+	// Трейлеры известны только после чтения всего тела
+	for key, values := range response.Trailer {
+		w.Header()[http.TrailerPrefix+key] = values
+	}
+
+	recording.finish()
+}
+
+// copyWithFlush передаёт тело клиенту, отправляя каждый прочитанный фрагмент сразу.
+func copyWithFlush(w http.ResponseWriter, body io.Reader) error {
+	flusher, _ := w.(http.Flusher)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := body.Read(buf)
+		if n > 0 {
+			if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+				return writeErr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func writeBadGateway(conn net.Conn) {
+	response := &http.Response{
+		StatusCode: http.StatusBadGateway,
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Close:      true,
+	}
+	if err := response.Write(conn); err != nil {
+		log.Println("Error sending response to client:", err)
+	}
+}
+
+// isClosedOrIdle сообщает, что клиент закрыл соединение или молчал дольше таймаута простоя.
+func isClosedOrIdle(err error) bool {
+	if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, net.ErrClosed) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// upstreamHost возвращает имя целевого сервера с портом target: SNI клиента
+// перехваченного TLS, а без него — имя из заголовка Host. Адрес target бывает IP
+// (CONNECT или SOCKS5 по IP, SO_ORIGINAL_DST), а транспорт берёт из URL имя для
+// SNI и проверки сертификата. Без имени остаётся target.
+func upstreamHost(request *http.Request, target string) string {
+	_, port, err := net.SplitHostPort(target)
+	if err != nil {
+		return target
+	}
+
+	name, _ := request.Context().Value(serverNameContextKey).(string)
+	if name == "" {
+		name = (&url.URL{Host: request.Host}).Hostname()
+	}
+	if name == "" {
+		return target
+	}
+	return net.JoinHostPort(name, port)
+}
+
+// targetAddress возвращает host:port цели, подставляя порт по умолчанию, если он не указан.
+func targetAddress(host, fallback, defaultPort string) string {
+	if host == "" {
+		host = fallback
+	}
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
 	}
+	return net.JoinHostPort(strings.Trim(host, "[]"), defaultPort)
 }
@@ -0,0 +1,452 @@
+package proxy
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bocharovatd/mitm-proxy/internal/config"
+	"github.com/bocharovatd/mitm-proxy/internal/passthrough"
+	passthroughEntity "github.com/bocharovatd/mitm-proxy/internal/passthrough/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/ca"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/upstream"
+	"github.com/bocharovatd/mitm-proxy/internal/request"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+)
+
+type requestUsecaseStub struct{ request.Usecase }
+
+func (requestUsecaseStub) Save(*requestEntity.HTTPRequest, *requestEntity.HTTPResponse, requestEntity.Metadata) (string, error) {
+	return "", nil
+}
+
+// streamingUpstream отвечает без Content-Length и без chunked: конец тела — закрытие
+// соединения. Вторая часть тела отправляется только после сигнала в proceed.
+func streamingUpstream(t *testing.T, proceed <-chan struct{}) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				defer conn.Close()
+				if _, err := http.ReadRequest(bufio.NewReader(conn)); err != nil {
+					return
+				}
+				fmt.Fprint(conn, "HTTP/1.1 200 OK\r\nContent-Type: text/plain\r\n\r\nfirst;")
+				<-proceed
+				fmt.Fprint(conn, "second")
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func TestHandleConnectionStreamedBodyWithoutLength(t *testing.T) {
+	tests := []struct {
+		name      string
+		proto     string
+		wantReuse bool
+	}{
+		{name: "HTTP/1.1 client gets chunked body and keeps connection", proto: "HTTP/1.1", wantReuse: true},
+		{name: "HTTP/1.0 client gets close-delimited body", proto: "HTTP/1.0", wantReuse: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			proceed := make(chan struct{})
+			target := streamingUpstream(t, proceed)
+
+			transport, err := upstream.New(config.UpstreamConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			handlers := NewProxyHandlers(nil, requestUsecaseStub{}, nil, nil, transport, config.ProxyConfig{
+				ClientIdleTimeout: 5 * time.Second,
+				BodyCaptureLimit:  1 << 20,
+				StreamRecordLimit: 10,
+			})
+
+			client, server := net.Pipe()
+			defer client.Close()
+			go handlers.HandleConnection(server)
+			client.SetDeadline(time.Now().Add(5 * time.Second))
+
+			send := func() {
+				header := ""
+				if tt.proto == "HTTP/1.0" {
+					header = "Connection: keep-alive\r\n"
+				}
+				fmt.Fprintf(client, "GET http://%s/ %s\r\nHost: %s\r\n%s\r\n", target, tt.proto, target, header)
+			}
+
+			reader := bufio.NewReader(client)
+			send()
+			response, err := http.ReadResponse(reader, nil)
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+
+			// Первая часть должна дойти до клиента раньше, чем целевой сервер закончит ответ
+			first := make([]byte, len("first;"))
+			if _, err := io.ReadFull(response.Body, first); err != nil || string(first) != "first;" {
+				t.Fatalf("first part = %q, %v", first, err)
+			}
+			close(proceed)
+
+			rest, err := io.ReadAll(response.Body)
+			if err != nil || string(rest) != "second" {
+				t.Fatalf("rest of body = %q, %v", rest, err)
+			}
+
+			chunked := len(response.TransferEncoding) > 0 && response.TransferEncoding[0] == "chunked"
+			if chunked != tt.wantReuse || response.Close == tt.wantReuse {
+				t.Fatalf("chunked = %v, close = %v, want reuse %v", chunked, response.Close, tt.wantReuse)
+			}
+
+			if !tt.wantReuse {
+				if _, err := reader.ReadByte(); err != io.EOF {
+					t.Fatalf("connection not closed after close-delimited body: %v", err)
+				}
+				return
+			}
+
+			send()
+			response, err = http.ReadResponse(reader, nil)
+			if err != nil {
+				t.Fatalf("second request on the same connection failed: %v", err)
+			}
+			body, err := io.ReadAll(response.Body)
+			if err != nil || string(body) != "first;second" {
+				t.Fatalf("second body = %q, %v", body, err)
+			}
+		})
+	}
+}
+
+type certificateUsecaseStub struct{ authority *ca.Authority }
+
+func (stub certificateUsecaseStub) GetCertificate(host string) (tls.Certificate, error) {
+	return stub.authority.Sign(host)
+}
+
+type passthroughUsecaseStub struct{ passthrough.Usecase }
+
+func (passthroughUsecaseStub) IsPassthrough(string) bool  { return false }
+func (passthroughUsecaseStub) RecordSuccess(string) error { return nil }
+func (passthroughUsecaseStub) RecordFailure(string, error) (bool, error) {
+	return false, nil
+}
+
+// testAuthority создаёт CA во временном каталоге и возвращает его вместе с путём
+// к сертификату, которому доверяют клиент и транспорт к целевому серверу.
+func testAuthority(t *testing.T) (*ca.Authority, string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	authority, err := ca.Load(certPath, keyPath, time.Hour, ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authority, certPath
+}
+
+// connectTunnel открывает туннель к target через HTTP CONNECT.
+func connectTunnel(t *testing.T, handlers *ProxyHandlers, target string) net.Conn {
+	client, server := net.Pipe()
+	go handlers.HandleConnection(server)
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	fmt.Fprintf(client, "CONNECT %s HTTP/1.1\r\nHost: %s\r\n\r\n", target, target)
+	response, err := http.ReadResponse(bufio.NewReader(client), &http.Request{Method: http.MethodConnect})
+	if err != nil || response.StatusCode != http.StatusOK {
+		t.Fatalf("CONNECT failed: %v %v", response, err)
+	}
+	return client
+}
+
+func TestInterceptTLSToIPTarget(t *testing.T) {
+	authority, caPath := testAuthority(t)
+
+	// У целевого сервера сертификат только с DNS-именем, без IP в SAN
+	originCert, err := authority.Sign("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	origin := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "sni=%s host=%s", r.TLS.ServerName, r.Host)
+	}))
+	origin.TLS = &tls.Config{Certificates: []tls.Certificate{originCert}}
+	origin.StartTLS()
+	defer origin.Close()
+	target := origin.Listener.Addr().String()
+
+	transport, err := upstream.New(config.UpstreamConfig{
+		TLSRules: []config.UpstreamTLSRule{{Hosts: []string{"*"}, CAFile: caPath}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handlers := NewProxyHandlers(certificateUsecaseStub{authority}, requestUsecaseStub{}, nil, passthroughUsecaseStub{}, transport, config.ProxyConfig{
+		ClientIdleTimeout: 5 * time.Second,
+		BodyCaptureLimit:  1 << 20,
+		StreamRecordLimit: 10,
+	}).(*ProxyHandlers)
+
+	roots := x509.NewCertPool()
+	caPEM, _ := os.ReadFile(caPath)
+	roots.AppendCertsFromPEM(caPEM)
+
+	tests := []struct {
+		name    string
+		tunnel  func(t *testing.T, handlers *ProxyHandlers, target string) net.Conn
+		sni     string
+		host    string
+		wantSNI string
+	}{
+		{name: "CONNECT to IP", tunnel: connectTunnel, sni: "example.com", host: "example.com", wantSNI: "example.com"},
+		// Как в прозрачном режиме: адрес из SO_ORIGINAL_DST, имя есть только в SNI
+		{name: "SNI preferred over IP in Host", tunnel: connectTunnel, sni: "example.com", host: target, wantSNI: "example.com"},
+		{name: "SOCKS5 to IPv4 address", tunnel: socksTunnel, sni: "example.com", host: "example.com", wantSNI: "example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := tls.Client(tt.tunnel(t, handlers, target), &tls.Config{ServerName: tt.sni, RootCAs: roots})
+			defer client.Close()
+
+			fmt.Fprintf(client, "GET / HTTP/1.1\r\nHost: %s\r\n\r\n", tt.host)
+			response, err := http.ReadResponse(bufio.NewReader(client), nil)
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			body, _ := io.ReadAll(response.Body)
+
+			want := fmt.Sprintf("sni=%s host=%s", tt.wantSNI, tt.host)
+			if response.StatusCode != http.StatusOK || string(body) != want {
+				t.Fatalf("response = %s %q, want 200 %q", response.Status, body, want)
+			}
+		})
+	}
+}
+
+// passthroughRecorder запоминает, какие исходы рукопожатий сообщил обработчик.
+type passthroughRecorder struct {
+	passthroughUsecaseStub
+	results chan error
+}
+
+func (r passthroughRecorder) RecordSuccess(string) error {
+	r.results <- nil
+	return nil
+}
+
+func (r passthroughRecorder) RecordFailure(_ string, err error) (bool, error) {
+	r.results <- err
+	return false, nil
+}
+
+func TestInterceptTLSClientClosesAfterHandshake(t *testing.T) {
+	authority, caPath := testAuthority(t)
+	roots := x509.NewCertPool()
+	caPEM, _ := os.ReadFile(caPath)
+	roots.AppendCertsFromPEM(caPEM)
+
+	tests := []struct {
+		name string
+		// client действует после успешного рукопожатия
+		client      func(conn *tls.Conn)
+		wantFailure error
+	}{
+		{
+			name:        "pinned client closes without data",
+			client:      func(conn *tls.Conn) { conn.Close() },
+			wantFailure: passthroughEntity.ErrClosedAfterHandshake,
+		},
+		{
+			name:   "client sends request",
+			client: func(conn *tls.Conn) { fmt.Fprint(conn, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n") },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := passthroughRecorder{results: make(chan error, 1)}
+			transport, err := upstream.New(config.UpstreamConfig{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			handlers := NewProxyHandlers(certificateUsecaseStub{authority}, requestUsecaseStub{}, nil, recorder, transport, config.ProxyConfig{
+				ClientIdleTimeout: 5 * time.Second,
+				BodyCaptureLimit:  1 << 20,
+				StreamRecordLimit: 10,
+			}).(*ProxyHandlers)
+
+			// Закрывается только туннель: close_notify с обеих сторон net.Pipe заблокировался бы до дедлайна
+			tunnel := connectTunnel(t, handlers, "127.0.0.1:1")
+			defer tunnel.Close()
+			client := tls.Client(tunnel, &tls.Config{ServerName: "example.com", RootCAs: roots})
+			if err := client.Handshake(); err != nil {
+				t.Fatalf("handshake failed: %v", err)
+			}
+			tt.client(client)
+
+			select {
+			case got := <-recorder.results:
+				if (tt.wantFailure == nil) != (got == nil) || tt.wantFailure != nil && !errors.Is(got, tt.wantFailure) {
+					t.Errorf("recorded %v, want %v", got, tt.wantFailure)
+				}
+			case <-time.After(3 * time.Second):
+				t.Fatal("handshake outcome was not recorded")
+			}
+		})
+	}
+}
+
+// requestRecorder передаёт сохранённые записи в канал.
+type requestRecorder struct {
+	request.Usecase
+	saved chan requestEntity.Metadata
+}
+
+func (r requestRecorder) Save(_ *requestEntity.HTTPRequest, _ *requestEntity.HTTPResponse, metadata requestEntity.Metadata) (string, error) {
+	r.saved <- metadata
+	return "", nil
+}
+
+func TestFailedUpstreamHandshakeIsSaved(t *testing.T) {
+	authority, caPath := testAuthority(t)
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "example.com"},
+		DNSNames:     []string{"example.com"},
+		NotBefore:    time.Now().Add(-48 * time.Hour),
+		NotAfter:     time.Now().Add(-24 * time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired := tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+	valid, err := authority.Sign("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	caPEM, _ := os.ReadFile(caPath)
+	roots.AppendCertsFromPEM(caPEM)
+
+	tests := []struct {
+		name        string
+		originTLS   *tls.Config
+		wantExpired bool
+		wantWeak    bool
+	}{
+		{name: "expired certificate", originTLS: &tls.Config{Certificates: []tls.Certificate{expired}}, wantExpired: true},
+		{name: "TLS 1.0 only", originTLS: &tls.Config{Certificates: []tls.Certificate{valid}, MaxVersion: tls.VersionTLS10}, wantWeak: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			origin := httptest.NewUnstartedServer(http.NotFoundHandler())
+			origin.TLS = tt.originTLS
+			origin.Config.ErrorLog = log.New(io.Discard, "", 0)
+			origin.StartTLS()
+			defer origin.Close()
+
+			transport, err := upstream.New(config.UpstreamConfig{
+				TLSRules: []config.UpstreamTLSRule{{Hosts: []string{"*"}, CAFile: caPath}},
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			recorder := requestRecorder{saved: make(chan requestEntity.Metadata, 1)}
+			handlers := NewProxyHandlers(certificateUsecaseStub{authority}, recorder, nil, passthroughUsecaseStub{}, transport, config.ProxyConfig{
+				ClientIdleTimeout: 5 * time.Second,
+				BodyCaptureLimit:  1 << 20,
+				StreamRecordLimit: 10,
+			}).(*ProxyHandlers)
+
+			// Прокси сам закрывает соединение после 502, поэтому закрывается только туннель:
+			// close_notify в net.Pipe без читающей стороны заблокировался бы до дедлайна
+			tunnel := connectTunnel(t, handlers, origin.Listener.Addr().String())
+			defer tunnel.Close()
+			client := tls.Client(tunnel, &tls.Config{ServerName: "example.com", RootCAs: roots})
+
+			fmt.Fprint(client, "GET / HTTP/1.1\r\nHost: example.com\r\n\r\n")
+			response, err := http.ReadResponse(bufio.NewReader(client), nil)
+			if err != nil {
+				t.Fatalf("failed to read response: %v", err)
+			}
+			if response.StatusCode != http.StatusBadGateway {
+				t.Fatalf("status = %s, want 502", response.Status)
+			}
+
+			metadata := <-recorder.saved
+			upstreamTLS := metadata.UpstreamTLS
+			if upstreamTLS == nil || upstreamTLS.HandshakeError == "" {
+				t.Fatalf("upstream TLS = %+v, want handshake error", upstreamTLS)
+			}
+			if upstreamTLS.ExpiredCertificate != tt.wantExpired || upstreamTLS.WeakProtocol != tt.wantWeak {
+				t.Errorf("expired, weak = %v, %v, want %v, %v", upstreamTLS.ExpiredCertificate, upstreamTLS.WeakProtocol, tt.wantExpired, tt.wantWeak)
+			}
+		})
+	}
+}
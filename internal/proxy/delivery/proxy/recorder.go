@@ -0,0 +1,110 @@
+package proxy
+
+import (
+	"log"
+	"sync/atomic"
+)
+
+const (
+	// streamRecordQueue — сколько элементов ждёт записи; при переполнении новые
+	// отбрасываются, чтобы медленная MongoDB не задерживала передачу клиенту.
+	streamRecordQueue = 256
+	streamRecordBatch = 64
+	// streamRecordBytes держит запись с событиями или сообщениями далеко от
+	// предела документа MongoDB в 16 МБ.
+	streamRecordBytes = 8 << 20
+)
+
+// streamRecorder дописывает элементы потока (события SSE, сообщения WebSocket) к
+// записи в фоне, пачками. После limit элементов или streamRecordBytes байт, а также
+// при переполнении очереди элементы отбрасываются и запись помечается обрезанной.
+type streamRecorder[T any] struct {
+	queue   chan T
+	done    chan struct{}
+	dropped atomic.Bool
+	limit   int
+	size    func(T) int
+	flush   func(items []T, truncated bool) error
+}
+
+func newStreamRecorder[T any](limit int, size func(T) int, flush func(items []T, truncated bool) error) *streamRecorder[T] {
+	recorder := &streamRecorder[T]{
+		queue: make(chan T, streamRecordQueue),
+		done:  make(chan struct{}),
+		limit: limit,
+		size:  size,
+		flush: flush,
+	}
+	go recorder.run()
+	return recorder
+}
+
+// Add не блокирует вызывающего; вызывать до Close.
+func (recorder *streamRecorder[T]) Add(item T) {
+	select {
+	case recorder.queue <- item:
+	default:
+		recorder.dropped.Store(true)
+	}
+}
+
+// Close дожидается записи всех принятых элементов.
+func (recorder *streamRecorder[T]) Close() {
+	close(recorder.queue)
+	<-recorder.done
+}
+
+func (recorder *streamRecorder[T]) run() {
+	defer close(recorder.done)
+
+	count, bytes := 0, 0
+	truncated, flagged := false, false
+	batch := make([]T, 0, streamRecordBatch)
+
+	write := func() {
+		if len(batch) == 0 && truncated == flagged {
+			return
+		}
+		if err := recorder.flush(batch, truncated); err != nil {
+			log.Printf("Failed to record stream: %v", err)
+		}
+		flagged = truncated
+		batch = batch[:0]
+	}
+
+	for item := range recorder.queue {
+		// Забираем всё, что уже накопилось в очереди, и пишем одним запросом
+		for {
+			size := recorder.size(item)
+			if count >= recorder.limit || bytes+size > streamRecordBytes {
+				truncated = true
+			} else {
+				count++
+				bytes += size
+				batch = append(batch, item)
+			}
+
+			if len(batch) == streamRecordBatch {
+				break
+			}
+			var ok bool
+			select {
+			case item, ok = <-recorder.queue:
+			default:
+			}
+			if !ok {
+				break
+			}
+		}
+
+		if recorder.dropped.Load() {
+			truncated = true
+		}
+		write()
+	}
+
+	if recorder.dropped.Load() {
+		truncated = true
+	}
+	write()
+}
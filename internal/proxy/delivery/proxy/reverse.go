@@ -0,0 +1,67 @@
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"time"
+
+	"golang.org/x/net/http2"
+
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+)
+
+// HandleReverseConnection обслуживает клиента обратного прокси: все запросы
+// передаются на один целевой сервер из настроек с его заголовком Host. Если
+// задан Hostname, прокси принимает TLS с сертификатом на это имя.
+func (handlers *ProxyHandlers) HandleReverseConnection(conn net.Conn) {
+	defer conn.Close()
+
+	if !handlers.clientAllowed(conn) {
+		return
+	}
+
+	upstream := handlers.cfg.Reverse.Upstream
+	target := targetAddress(upstream.Host, "", requestEntity.DefaultPort(upstream.Scheme))
+
+	hostname := handlers.cfg.Reverse.Hostname
+	if hostname == "" {
+		handlers.serveHTTP(context.Background(), conn, bufio.NewReader(conn), upstream.Scheme, target, upstream.Host)
+		return
+	}
+
+	tlsConfig := &tls.Config{
+		// Сертификат выпускается на настроенное имя независимо от SNI
+		GetCertificate: func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+			cert, err := handlers.usecase.GetCertificate(hostname)
+			if err != nil {
+				log.Printf("Failed to get certificate for %s: %v", hostname, err)
+				return nil, err
+			}
+			return &cert, nil
+		},
+	}
+
+	if handlers.cfg.HTTP2 {
+		tlsConfig.NextProtos = []string{http2.NextProtoTLS, "http/1.1"}
+	}
+
+	tlsConn := tls.Server(conn, tlsConfig)
+	defer tlsConn.Close()
+
+	tlsConn.SetDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+	if err := tlsConn.Handshake(); err != nil {
+		log.Printf("TLS handshake with reverse proxy client %s failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+	tlsConn.SetDeadline(time.Time{})
+
+	if tlsConn.ConnectionState().NegotiatedProtocol == http2.NextProtoTLS {
+		handlers.serveHTTP2(context.Background(), tlsConn, upstream.Scheme, target, upstream.Host)
+		return
+	}
+
+	handlers.serveHTTP(context.Background(), tlsConn, bufio.NewReader(tlsConn), upstream.Scheme, target, upstream.Host)
+}
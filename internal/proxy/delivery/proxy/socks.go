@@ -0,0 +1,47 @@
+package proxy
+
+import (
+	"log"
+	"net"
+	"time"
+
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/socks5"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+)
+
+// HandleSOCKSConnection принимает соединение SOCKS5 и по первым байтам потока
+// выбирает обработку: TLS перехватывается так же, как после CONNECT, HTTP
+// проксируется по запросам, а остальные протоколы передаются без изменений.
+func (handlers *ProxyHandlers) HandleSOCKSConnection(conn net.Conn) {
+	defer conn.Close()
+
+	if !handlers.clientAllowed(conn) {
+		return
+	}
+
+	conn.SetReadDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+	target, user, err := socks5.Handshake(conn, handlers.socksAuthenticator())
+	if err != nil {
+		log.Printf("SOCKS5 handshake with %s failed: %v", conn.RemoteAddr(), err)
+		return
+	}
+
+	reader, kind, err := sniffStream(conn)
+	if err != nil {
+		return
+	}
+
+	buffered := &bufferedConn{Conn: conn, reader: reader}
+	// Записи и запросы внутри перехваченного TLS получают пользователя SOCKS5
+	request := withUser(connectRequest(target, "SOCKS5"), user)
+
+	switch kind {
+	case streamTLS:
+		handlers.interceptTLS(buffered, request)
+	case streamHTTP:
+		handlers.serveHTTP(request.Context(), conn, reader, "http", target, "")
+	default:
+		log.Printf("Unknown protocol from %s to %s over SOCKS5, relaying raw", conn.RemoteAddr(), target)
+		handlers.tunnel(buffered, request, target, "", requestEntity.Tunnel{Raw: true})
+	}
+}
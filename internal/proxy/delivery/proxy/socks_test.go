@@ -0,0 +1,42 @@
+package proxy
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+// socksTunnel открывает туннель к target через SOCKS5 с адресом типа IPv4 или
+// IPv6, как у клиентов, которые сами разрешают имена (curl --socks5).
+func socksTunnel(t *testing.T, handlers *ProxyHandlers, target string) net.Conn {
+	addrPort, err := netip.ParseAddrPort(target)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	client, server := net.Pipe()
+	go handlers.HandleSOCKSConnection(server)
+	client.SetDeadline(time.Now().Add(5 * time.Second))
+
+	request := []byte{0x05, 0x01, 0x00, 0x01}
+	if addrPort.Addr().Is6() {
+		request[3] = 0x04
+	}
+	request = append(request, addrPort.Addr().AsSlice()...)
+	request = binary.BigEndian.AppendUint16(request, addrPort.Port())
+
+	go client.Write(append([]byte{0x05, 0x01, 0x00}, request...))
+
+	reply := make([]byte, 2+10)
+	if _, err := io.ReadFull(client, reply); err != nil {
+		t.Fatalf("SOCKS5 handshake failed: %v", err)
+	}
+	if !bytes.Equal(reply[:4], []byte{0x05, 0x00, 0x05, 0x00}) {
+		t.Fatalf("SOCKS5 reply = % x", reply)
+	}
+	return client
+}
@@ -0,0 +1,137 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// sniffTimeout — сколько ждать первых байт от клиента. Протоколы, в которых
+// первым говорит сервер, по истечении этого времени передаются как есть.
+const sniffTimeout = 2 * time.Second
+
+// tlsRecordHandshake — тип первой записи TLS, в которой приходит ClientHello.
+const tlsRecordHandshake = 0x16
+
+var httpMethods = [][]byte{
+	[]byte("GET "), []byte("HEAD "), []byte("POST "), []byte("PUT "), []byte("DELETE "),
+	[]byte("OPTIONS "), []byte("PATCH "), []byte("TRACE "),
+}
+
+// streamKind — протокол потока, определённый по первым байтам.
+type streamKind int
+
+const (
+	streamUnknown streamKind = iota
+	streamTLS
+	streamHTTP
+)
+
+// sniffStream ждёт первых байт от клиента и определяет протокол потока. Прочитанные
+// байты остаются в возвращаемом reader. Ошибка возвращается, только если клиент
+// закрыл соединение, ничего не отправив.
+func sniffStream(conn net.Conn) (*bufio.Reader, streamKind, error) {
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(time.Now().Add(sniffTimeout))
+	data, err := reader.Peek(1)
+	conn.SetReadDeadline(time.Time{})
+
+	if err != nil {
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			return reader, streamUnknown, nil
+		}
+		return nil, streamUnknown, err
+	}
+
+	if data[0] == tlsRecordHandshake {
+		return reader, streamTLS, nil
+	}
+
+	// Смотрятся только уже полученные байты: строка запроса обычно приходит в первом сегменте
+	data, _ = reader.Peek(reader.Buffered())
+	for _, method := range httpMethods {
+		if bytes.HasPrefix(data, method) {
+			return reader, streamHTTP, nil
+		}
+	}
+
+	return reader, streamUnknown, nil
+}
+
+// serveHTTP проксирует запросы HTTP/1.x, отправленные клиентом напрямую на
+// целевой сервер, а не прокси. Пустой target — адрес берётся из заголовка Host,
+// непустой host заменяет этот заголовок. Запросы получают контекст ctx.
+func (handlers *ProxyHandlers) serveHTTP(ctx context.Context, conn net.Conn, reader *bufio.Reader, scheme, target, host string) {
+	for {
+		conn.SetReadDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+
+		request, err := http.ReadRequest(reader)
+		if err != nil {
+			if !isClosedOrIdle(err) {
+				log.Println("Error reading request:", err)
+			}
+			return
+		}
+		conn.SetReadDeadline(time.Time{})
+		request = request.WithContext(ctx)
+
+		requestTarget := target
+		if requestTarget == "" {
+			if request.Host == "" {
+				log.Printf("Request from %s has no Host header, closing", conn.RemoteAddr())
+				writeBadGateway(conn)
+				return
+			}
+			requestTarget = targetAddress(request.Host, "", "80")
+		}
+		if host != "" {
+			request.Host = host
+		}
+
+		if err := handlers.HandleHTTPConnection(conn, reader, request, scheme, requestTarget); err != nil {
+			log.Println("Error handling request:", err)
+			return
+		}
+
+		if request.Close {
+			return
+		}
+	}
+}
+
+// connectRequest описывает соединение с target так же, как запрос CONNECT
+// HTTP-прокси, чтобы туннели и перехват TLS записывались одинаково.
+// proto — протокол, по которому клиент подключился к прокси.
+func connectRequest(target, proto string) *http.Request {
+	return &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Host: target},
+		Host:   target,
+		Proto:  proto,
+		Header: http.Header{},
+	}
+}
+
+// bufferedConn отдаёт сначала байты, уже прочитанные в reader при определении протокола.
+type bufferedConn struct {
+	net.Conn
+	reader io.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+func (c *bufferedConn) CloseWrite() error {
+	closeWrite(c.Conn)
+	return nil
+}
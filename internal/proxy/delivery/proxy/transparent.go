@@ -0,0 +1,60 @@
+package proxy
+
+import (
+	"context"
+	"log"
+	"net"
+	"time"
+
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/clienthello"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/origdst"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+)
+
+// HandleTransparentConnection обрабатывает соединение, перенаправленное на прокси
+// правилом iptables. Адрес назначения берётся из SO_ORIGINAL_DST, а если его нет
+// (прямое подключение к порту или не Linux) — из SNI или заголовка Host.
+func (handlers *ProxyHandlers) HandleTransparentConnection(conn net.Conn) {
+	defer conn.Close()
+
+	if !handlers.clientAllowed(conn) {
+		return
+	}
+
+	target := ""
+	if addr, err := origdst.Lookup(conn); err == nil && addr.String() != conn.LocalAddr().String() {
+		target = addr.String()
+	}
+
+	reader, kind, err := sniffStream(conn)
+	if err != nil {
+		return
+	}
+
+	var client net.Conn = &bufferedConn{Conn: conn, reader: reader}
+
+	switch kind {
+	case streamTLS:
+		if target == "" {
+			conn.SetReadDeadline(time.Now().Add(handlers.cfg.ClientIdleTimeout))
+			serverName, replay, err := clienthello.PeekServerName(client)
+			conn.SetReadDeadline(time.Time{})
+			if serverName == "" {
+				log.Printf("No original destination or SNI for TLS connection from %s: %v", conn.RemoteAddr(), err)
+				return
+			}
+			client = replay
+			target = net.JoinHostPort(serverName, "443")
+		}
+		handlers.interceptTLS(client, connectRequest(target, "TCP"))
+	case streamHTTP:
+		handlers.serveHTTP(context.Background(), conn, reader, "http", target, "")
+	default:
+		if target == "" {
+			log.Printf("Unknown protocol from %s without original destination, closing", conn.RemoteAddr())
+			return
+		}
+		log.Printf("Unknown protocol from %s to %s, relaying raw", conn.RemoteAddr(), target)
+		handlers.tunnel(client, connectRequest(target, "TCP"), target, "", requestEntity.Tunnel{Raw: true})
+	}
+}
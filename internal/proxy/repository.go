@@ -7,4 +7,5 @@ import (
 type Repository interface {
 	SaveCertificate(domain string, cert tls.Certificate) error
 	GetCertificateByDomain(domain string) (*tls.Certificate, error)
+	Migrate() error
 }
@@ -2,7 +2,6 @@ package proxy
 
 import (
 	"context"
-	"crypto/rsa"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
@@ -12,7 +11,9 @@ import (
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/ca"
 	"github.com/bocharovatd/mitm-proxy/internal/proxy"
 )
 
@@ -36,15 +37,24 @@ type CertificateDocument struct {
 	ExpiresAt time.Time          `bson:"expires_at"`
 }
 
+// SaveCertificate сохраняет всю цепочку сертификата (лист и CA) в cert_pem.
 func (r *ProxyRepository) SaveCertificate(domain string, cert tls.Certificate) error {
-	certPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "CERTIFICATE",
-		Bytes: cert.Certificate[0],
-	})
+	var certPEM []byte
+	for _, der := range cert.Certificate {
+		certPEM = append(certPEM, pem.EncodeToMemory(&pem.Block{
+			Type:  "CERTIFICATE",
+			Bytes: der,
+		})...)
+	}
+
+	keyDER, err := x509.MarshalPKCS8PrivateKey(cert.PrivateKey)
+	if err != nil {
+		return fmt.Errorf("failed to marshal private key: %w", err)
+	}
 
 	keyPEM := pem.EncodeToMemory(&pem.Block{
-		Type:  "RSA PRIVATE KEY",
-		Bytes: x509.MarshalPKCS1PrivateKey(cert.PrivateKey.(*rsa.PrivateKey)),
+		Type:  "PRIVATE KEY",
+		Bytes: keyDER,
 	})
 
 	x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
@@ -52,15 +62,16 @@ func (r *ProxyRepository) SaveCertificate(domain string, cert tls.Certificate) e
 		return fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
-	doc := CertificateDocument{
-		Domain:    domain,
-		CertPEM:   string(certPEM),
-		KeyPEM:    string(keyPEM),
-		CreatedAt: time.Now(),
-		ExpiresAt: x509Cert.NotAfter,
-	}
-
-	_, err = r.mongoCollection.InsertOne(context.Background(), doc)
+	filter := bson.M{"domain": domain}
+	update := bson.M{"$set": bson.M{
+		"domain":     domain,
+		"cert_pem":   string(certPEM),
+		"key_pem":    string(keyPEM),
+		"created_at": time.Now(),
+		"expires_at": x509Cert.NotAfter,
+	}}
+
+	_, err = r.mongoCollection.UpdateOne(context.Background(), filter, update, options.Update().SetUpsert(true))
 	if err != nil {
 		return fmt.Errorf("failed to save certificate: %w", err)
 	}
@@ -80,8 +91,17 @@ func (r *ProxyRepository) GetCertificateByDomain(domain string) (*tls.Certificat
 		return nil, fmt.Errorf("failed to get certificate: %w", err)
 	}
 
-	certBlock, _ := pem.Decode([]byte(doc.CertPEM))
-	if certBlock == nil {
+	var chain [][]byte
+	rest := []byte(doc.CertPEM)
+	for {
+		var certBlock *pem.Block
+		certBlock, rest = pem.Decode(rest)
+		if certBlock == nil {
+			break
+		}
+		chain = append(chain, certBlock.Bytes)
+	}
+	if len(chain) == 0 {
 		return nil, fmt.Errorf("failed to decode certificate PEM")
 	}
 
@@ -90,15 +110,75 @@ func (r *ProxyRepository) GetCertificateByDomain(domain string) (*tls.Certificat
 		return nil, fmt.Errorf("failed to decode key PEM")
 	}
 
-	privateKey, err := x509.ParsePKCS1PrivateKey(keyBlock.Bytes)
+	privateKey, err := ca.ParsePrivateKey(keyBlock.Bytes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse private key: %w", err)
 	}
 
 	cert := tls.Certificate{
-		Certificate: [][]byte{certBlock.Bytes},
+		Certificate: chain,
 		PrivateKey:  privateKey,
 	}
 
 	return &cert, nil
 }
+
+// Migrate удаляет дубликаты сертификатов, оставляя самый свежий для каждого домена,
+// и создаёт уникальный индекс по domain и TTL-индекс по expires_at.
+func (r *ProxyRepository) Migrate() error {
+	ctx := context.Background()
+
+	pipeline := mongo.Pipeline{
+		{{Key: "$sort", Value: bson.D{{Key: "expires_at", Value: -1}}}},
+		{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$domain"},
+			{Key: "ids", Value: bson.D{{Key: "$push", Value: "$_id"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+		}}},
+		{{Key: "$match", Value: bson.D{{Key: "count", Value: bson.D{{Key: "$gt", Value: 1}}}}}},
+	}
+
+	cursor, err := r.mongoCollection.Aggregate(ctx, pipeline)
+	if err != nil {
+		return fmt.Errorf("failed to find duplicate certificates: %w", err)
+	}
+	defer cursor.Close(ctx)
+
+	var stale []primitive.ObjectID
+	for cursor.Next(ctx) {
+		var group struct {
+			IDs []primitive.ObjectID `bson:"ids"`
+		}
+		if err := cursor.Decode(&group); err != nil {
+			return fmt.Errorf("failed to decode duplicate certificates: %w", err)
+		}
+		stale = append(stale, group.IDs[1:]...)
+	}
+	if err := cursor.Err(); err != nil {
+		return fmt.Errorf("cursor error while finding duplicate certificates: %w", err)
+	}
+
+	if len(stale) > 0 {
+		_, err := r.mongoCollection.DeleteMany(ctx, bson.M{"_id": bson.M{"$in": stale}})
+		if err != nil {
+			return fmt.Errorf("failed to delete duplicate certificates: %w", err)
+		}
+	}
+
+	indexes := []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "domain", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	}
+
+	if _, err := r.mongoCollection.Indexes().CreateMany(ctx, indexes); err != nil {
+		return fmt.Errorf("failed to create certificate indexes: %w", err)
+	}
+
+	return nil
+}
@@ -5,5 +5,5 @@ import (
 )
 
 type Usecase interface {
-	GetCertificate(domain string) (tls.Certificate, error)
+	GetCertificate(host string) (tls.Certificate, error)
 }
@@ -1,82 +1,109 @@
 package proxy
 
 import (
-	"bytes"
 	"crypto/tls"
 	"crypto/x509"
 	"fmt"
-	"math/big"
-	"os"
-	"os/exec"
+	"net"
+	"strings"
 	"time"
 
+	"golang.org/x/net/publicsuffix"
+	"golang.org/x/sync/singleflight"
+
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/ca"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/certcache"
 	"github.com/bocharovatd/mitm-proxy/internal/proxy"
 )
 
 type ProxyUsecase struct {
 	proxyRepository proxy.Repository
+	authority       *ca.Authority
+	cache           *certcache.Cache
+	group           singleflight.Group
+	wildcard        bool
 }
 
-func NewProxyUsecase(proxyRepo proxy.Repository) proxy.Usecase {
+func NewProxyUsecase(proxyRepo proxy.Repository, authority *ca.Authority, cache *certcache.Cache, wildcard bool) proxy.Usecase {
 	return &ProxyUsecase{
 		proxyRepository: proxyRepo,
+		authority:       authority,
+		cache:           cache,
+		wildcard:        wildcard,
+	}
+}
+
+func (usecase *ProxyUsecase) GetCertificate(host string) (tls.Certificate, error) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	key := usecase.certificateKey(host)
+
+	if cert, ok := usecase.cache.Get(key); ok {
+		return cert, nil
+	}
+
+	result, err, _ := usecase.group.Do(key, func() (interface{}, error) {
+		cert, err := usecase.loadCertificate(key, host)
+		if err != nil {
+			return tls.Certificate{}, err
+		}
+		usecase.cache.Add(key, cert)
+		return cert, nil
+	})
+	if err != nil {
+		return tls.Certificate{}, err
 	}
+
+	return result.(tls.Certificate), nil
 }
 
-func (usecase *ProxyUsecase) GetCertificate(domain string) (tls.Certificate, error) {
-	cert, err := usecase.proxyRepository.GetCertificateByDomain(domain)
+func (usecase *ProxyUsecase) loadCertificate(key, host string) (tls.Certificate, error) {
+	cert, err := usecase.proxyRepository.GetCertificateByDomain(key)
 	if err != nil {
 		return tls.Certificate{}, fmt.Errorf("failed to check certificate: %w", err)
 	}
 
-	if cert != nil {
+	// Сертификат, выпущенный до смены режима wildcard, может не покрывать хост.
+	// Записи без CA в цепочке сохранены старой версией и выпускаются заново
+	if cert != nil && len(cert.Certificate) > 1 {
 		x509Cert, err := x509.ParseCertificate(cert.Certificate[0])
-		if err == nil && x509Cert.NotAfter.After(time.Now().Add(24*time.Hour)) {
+		if err == nil && x509Cert.NotAfter.After(time.Now().Add(24*time.Hour)) && x509Cert.VerifyHostname(host) == nil {
+			cert.Leaf = x509Cert
 			return *cert, nil
 		}
 	}
 
-	newCert, err := usecase.generateCertificate(domain)
+	domain := strings.TrimPrefix(key, "*.")
+	hosts := []string{domain}
+	if usecase.wildcard && net.ParseIP(domain) == nil {
+		hosts = append(hosts, "*."+domain)
+	}
+
+	newCert, err := usecase.authority.Sign(hosts...)
 	if err != nil {
 		return tls.Certificate{}, fmt.Errorf("failed to generate certificate: %w", err)
 	}
 
-	if err := usecase.proxyRepository.SaveCertificate(domain, newCert); err != nil {
+	if err := usecase.proxyRepository.SaveCertificate(key, newCert); err != nil {
 		return tls.Certificate{}, fmt.Errorf("failed to save certificate: %w", err)
 	}
 
 	return newCert, nil
 }
 
-func (usecase *ProxyUsecase) generateCertificate(domain string) (tls.Certificate, error) {
-	scriptPath := "internal/scripts/gen_cert.sh"
-
-	err := os.Chmod(scriptPath, 0755)
-	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("failed to make script executable: %w", err)
-	}
-
-	serial := big.NewInt(time.Now().UnixNano())
-	cmd := exec.Command(scriptPath, domain, fmt.Sprintf("%d", serial))
-
-	var certOut bytes.Buffer
-	cmd.Stdout = &certOut
-
-	if err := cmd.Run(); err != nil {
-		return tls.Certificate{}, fmt.Errorf("script execution failed: %w", err)
+// certificateKey возвращает ключ, под которым хранится сертификат для хоста.
+// В режиме wildcard соседние поддомены (a.example.com, b.example.com) получают
+// общий сертификат для example.com и *.example.com с ключом *.example.com, чтобы
+// не путать его с сертификатом самого example.com.
+func (usecase *ProxyUsecase) certificateKey(host string) string {
+	if !usecase.wildcard || net.ParseIP(host) != nil {
+		return host
 	}
 
-	certPEM := certOut.Bytes()
-
-	keyPEM, err := os.ReadFile("certs/cert.key")
-	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("failed to read key file: %w", err)
-	}
-
-	tlsCert, err := tls.X509KeyPair(certPEM, keyPEM)
-	if err != nil {
-		return tls.Certificate{}, fmt.Errorf("failed to create key pair: %w", err)
+	registrable, err := publicsuffix.EffectiveTLDPlusOne(host)
+	if err != nil || registrable == host {
+		return host
 	}
 
-	return tlsCert, nil
+	_, parent, _ := strings.Cut(host, ".")
+	return "*." + parent
 }
@@ -0,0 +1,152 @@
+package proxy
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/ca"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/certcache"
+)
+
+// memoryRepository хранит сертификаты в памяти вместо MongoDB.
+type memoryRepository struct {
+	certificates map[string]tls.Certificate
+}
+
+func (r *memoryRepository) SaveCertificate(domain string, cert tls.Certificate) error {
+	r.certificates[domain] = tls.Certificate{Certificate: cert.Certificate, PrivateKey: cert.PrivateKey}
+	return nil
+}
+
+func (r *memoryRepository) GetCertificateByDomain(domain string) (*tls.Certificate, error) {
+	cert, ok := r.certificates[domain]
+	if !ok {
+		return nil, nil
+	}
+	return &cert, nil
+}
+
+func (r *memoryRepository) Migrate() error { return nil }
+
+// testAuthority создаёт CA во временном каталоге.
+func testAuthority(t *testing.T) *ca.Authority {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(365 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir := t.TempDir()
+	certPath, keyPath := filepath.Join(dir, "ca.crt"), filepath.Join(dir, "ca.key")
+	os.WriteFile(certPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600)
+	os.WriteFile(keyPath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER}), 0o600)
+
+	authority, err := ca.Load(certPath, keyPath, 30*24*time.Hour, ca.KeyECDSAP256)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return authority
+}
+
+func TestGetCertificateChain(t *testing.T) {
+	authority := testAuthority(t)
+	repository := &memoryRepository{certificates: map[string]tls.Certificate{}}
+
+	issued, err := NewProxyUsecase(repository, authority, certcache.New(10), false).GetCertificate("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	legacy, err := authority.Sign("legacy.example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	legacy.Certificate = legacy.Certificate[:1]
+	repository.certificates["legacy.example.com"] = legacy
+
+	// Новый экземпляр с пустым кэшем читает сертификаты из хранилища
+	usecase := NewProxyUsecase(repository, authority, certcache.New(10), false)
+
+	tests := []struct {
+		name string
+		host string
+		// wantLeaf — лист, который должен вернуться; nil — выпущен новый сертификат
+		wantLeaf []byte
+	}{
+		{name: "stored chain", host: "example.com", wantLeaf: issued.Certificate[0]},
+		{name: "stored leaf without CA is reissued", host: "legacy.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cert, err := usecase.GetCertificate(tt.host)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(cert.Certificate) != 2 || string(cert.Certificate[1]) != string(issued.Certificate[1]) {
+				t.Fatalf("chain has %d certificates, want leaf and CA", len(cert.Certificate))
+			}
+			if tt.wantLeaf != nil && string(cert.Certificate[0]) != string(tt.wantLeaf) {
+				t.Errorf("leaf was reissued, want the stored one")
+			}
+			if tt.wantLeaf == nil && string(cert.Certificate[0]) == string(legacy.Certificate[0]) {
+				t.Errorf("stored leaf was returned, want a new certificate")
+			}
+		})
+	}
+}
+
+func TestCertificateKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		wildcard bool
+		host     string
+		want     string
+	}{
+		{name: "wildcard disabled", host: "a.example.com", want: "a.example.com"},
+		{name: "registrable domain", wildcard: true, host: "example.com", want: "example.com"},
+		{name: "subdomain", wildcard: true, host: "a.example.com", want: "*.example.com"},
+		{name: "nested subdomain", wildcard: true, host: "a.b.example.com", want: "*.b.example.com"},
+		{name: "public suffix", wildcard: true, host: "example.co.uk", want: "example.co.uk"},
+		{name: "subdomain under public suffix", wildcard: true, host: "a.example.co.uk", want: "*.example.co.uk"},
+		{name: "IPv4 address", wildcard: true, host: "192.0.2.1", want: "192.0.2.1"},
+		{name: "IPv6 address", wildcard: true, host: "2001:db8::1", want: "2001:db8::1"},
+		{name: "single label", wildcard: true, host: "localhost", want: "localhost"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usecase := &ProxyUsecase{wildcard: tt.wildcard}
+			if got := usecase.certificateKey(tt.host); got != tt.want {
+				t.Errorf("certificateKey(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
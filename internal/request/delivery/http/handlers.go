@@ -1,42 +1,59 @@
 package http
 
 import (
+	"errors"
 	"html/template"
 	"log"
 	"net/http"
 
 	"github.com/gorilla/mux"
 
+	"github.com/bocharovatd/mitm-proxy/internal/grpc"
+	grpcEntity "github.com/bocharovatd/mitm-proxy/internal/grpc/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/websession"
 	"github.com/bocharovatd/mitm-proxy/internal/request"
 	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
 )
 
 type RequestHandlers struct {
-	usecase request.Usecase
-	tmpl    *template.Template
+	usecase     request.Usecase
+	grpcUsecase grpc.Usecase
+	tmpl        *template.Template
 }
 
-func NewRequestHandlers(requestUC request.Usecase) request.Handlers {
+func NewRequestHandlers(requestUC request.Usecase, grpcUC grpc.Usecase) request.Handlers {
 	tmpl := template.Must(template.ParseGlob("templates/*.html"))
 	return &RequestHandlers{
-		usecase: requestUC,
-		tmpl:    tmpl,
+		usecase:     requestUC,
+		grpcUsecase: grpcUC,
+		tmpl:        tmpl,
 	}
 }
 
 func (handlers *RequestHandlers) GetAll(w http.ResponseWriter, r *http.Request) {
-	records, err := handlers.usecase.GetAll()
+	filter := requestEntity.Filter{
+		WeakProtocol:       r.URL.Query().Get("weak_tls") != "",
+		ExpiredCertificate: r.URL.Query().Get("expired_cert") != "",
+	}
+
+	records, err := handlers.usecase.GetAll(filter)
 	if err != nil {
 		log.Printf("Failed to get all requests: %v", err)
 		return
 	}
 
 	data := struct {
-		Title   string
-		Records []*requestEntity.RequestRecord
+		Title     string
+		Records   []*requestEntity.RequestRecord
+		Filter    requestEntity.Filter
+		User      string
+		CSRFToken string
 	}{
-		Title:   "All Requests",
-		Records: records,
+		Title:     "All Requests",
+		Records:   records,
+		Filter:    filter,
+		User:      websession.User(r),
+		CSRFToken: websession.CSRFToken(r),
 	}
 
 	if err := handlers.tmpl.ExecuteTemplate(w, "requests.html", data); err != nil {
@@ -55,12 +72,21 @@ func (handlers *RequestHandlers) GetByID(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	call, err := handlers.grpcUsecase.Decode(record)
+	if err != nil {
+		log.Printf("Failed to decode gRPC call: %v", err)
+	}
+
 	data := struct {
-		Title  string
-		Record *requestEntity.RequestRecord
+		Title     string
+		Record    *requestEntity.RequestRecord
+		GRPC      *grpcEntity.Call
+		CSRFToken string
 	}{
-		Title:  "Request Details",
-		Record: record,
+		Title:     "Request Details",
+		Record:    record,
+		GRPC:      call,
+		CSRFToken: websession.CSRFToken(r),
 	}
 
 	if err := handlers.tmpl.ExecuteTemplate(w, "request_details.html", data); err != nil {
@@ -74,6 +100,10 @@ func (handlers *RequestHandlers) RepeatByID(w http.ResponseWriter, r *http.Reque
 	id := vars["requestID"]
 
 	newId, err := handlers.usecase.RepeatByID(id)
+	if errors.Is(err, requestEntity.ErrTruncatedBody) {
+		http.Error(w, "Request body was captured only partially (see MITM_BODY_CAPTURE_LIMIT) and cannot be repeated", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to repeat request: %v", err)
 		http.Redirect(w, r, "/requests", http.StatusSeeOther)
@@ -88,6 +118,10 @@ func (handlers *RequestHandlers) ScanByID(w http.ResponseWriter, r *http.Request
 	id := vars["requestID"]
 
 	result, details, err := handlers.usecase.ScanByID(id)
+	if errors.Is(err, requestEntity.ErrTruncatedBody) {
+		http.Error(w, "Request body was captured only partially (see MITM_BODY_CAPTURE_LIMIT) and cannot be scanned", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		log.Printf("Failed to scan request: %v", err)
 		http.Error(w, "Scan failed", http.StatusInternalServerError)
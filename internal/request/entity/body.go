@@ -0,0 +1,64 @@
+package entity
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// ErrTruncatedBody — тело запроса сохранено не полностью, поэтому его нельзя отправить повторно.
+var ErrTruncatedBody = errors.New("request body was captured only partially")
+
+// BodyCapture накапливает копию тела, проходящего через прокси, не больше limit байт.
+// Запись никогда не возвращает ошибку, чтобы не прерывать передачу тела клиенту.
+type BodyCapture struct {
+	mu        sync.Mutex
+	buf       bytes.Buffer
+	limit     int64
+	truncated bool
+}
+
+func (c *BodyCapture) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	remaining := c.limit - int64(c.buf.Len())
+	if int64(len(p)) > remaining {
+		c.truncated = true
+		if remaining > 0 {
+			c.buf.Write(p[:remaining])
+		}
+		return len(p), nil
+	}
+
+	c.buf.Write(p)
+	return len(p), nil
+}
+
+func (c *BodyCapture) Bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return bytes.Clone(c.buf.Bytes())
+}
+
+func (c *BodyCapture) Truncated() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.truncated
+}
+
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// CaptureBody возвращает тело, которое при чтении копируется в BodyCapture.
+func CaptureBody(body io.ReadCloser, limit int64) (io.ReadCloser, *BodyCapture) {
+	capture := &BodyCapture{limit: limit}
+	if body == nil || body == http.NoBody {
+		return body, capture
+	}
+	return &teeReadCloser{Reader: io.TeeReader(body, capture), Closer: body}, capture
+}
@@ -0,0 +1,60 @@
+package entity
+
+import (
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestCaptureBody(t *testing.T) {
+	tests := []struct {
+		name          string
+		limit         int64
+		chunks        []string
+		want          string
+		wantTruncated bool
+	}{
+		{name: "within limit", limit: 16, chunks: []string{"hello", " world"}, want: "hello world"},
+		{name: "exactly at limit", limit: 5, chunks: []string{"hel", "lo"}, want: "hello"},
+		{name: "cut inside write", limit: 4, chunks: []string{"hel", "lo"}, want: "hell", wantTruncated: true},
+		{name: "writes after limit", limit: 3, chunks: []string{"abc", "def", "ghi"}, want: "abc", wantTruncated: true},
+		{name: "zero limit", limit: 0, chunks: []string{"abc"}, want: "", wantTruncated: true},
+		{name: "empty body", limit: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, capture := CaptureBody(io.NopCloser(strings.NewReader(strings.Join(tt.chunks, ""))), tt.limit)
+
+			// Клиент получает тело целиком, независимо от предела
+			passed, err := io.ReadAll(body)
+			if err != nil || string(passed) != strings.Join(tt.chunks, "") {
+				t.Fatalf("read %q, %v, want full body", passed, err)
+			}
+
+			if string(capture.Bytes()) != tt.want || capture.Truncated() != tt.wantTruncated {
+				t.Errorf("captured %q truncated %v, want %q truncated %v", capture.Bytes(), capture.Truncated(), tt.want, tt.wantTruncated)
+			}
+		})
+	}
+}
+
+func TestBodyCaptureWrite(t *testing.T) {
+	capture := &BodyCapture{limit: 4}
+	for _, chunk := range []string{"ab", "cde", "f"} {
+		if n, err := capture.Write([]byte(chunk)); n != len(chunk) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v, want %d, nil", chunk, n, err, len(chunk))
+		}
+	}
+	if string(capture.Bytes()) != "abcd" || !capture.Truncated() {
+		t.Errorf("captured %q truncated %v, want %q truncated true", capture.Bytes(), capture.Truncated(), "abcd")
+	}
+}
+
+func TestCaptureBodyNoBody(t *testing.T) {
+	body, capture := CaptureBody(http.NoBody, 8)
+	if body != http.NoBody || len(capture.Bytes()) != 0 || capture.Truncated() {
+		t.Errorf("CaptureBody(NoBody) = %v, %q, %v", body, capture.Bytes(), capture.Truncated())
+	}
+}
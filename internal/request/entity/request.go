@@ -3,7 +3,11 @@ package entity
 import (
 	"bytes"
 	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
 	"io"
+	"net"
 	"net/http"
 	"net/url"
 	"strings"
@@ -14,39 +18,132 @@ import (
 
 type HTTPRequest struct {
 	Method     string                 `bson:"method"`
+	Scheme     string                 `bson:"scheme"`
+	Host       string                 `bson:"host"`
+	Port       string                 `bson:"port"`
 	Path       string                 `bson:"path"`
 	GetParams  map[string]interface{} `bson:"get_params"`
 	Headers    map[string]string      `bson:"headers"`
 	Cookies    map[string]string      `bson:"cookies"`
 	PostParams map[string]interface{} `bson:"post_params"`
 	RawBody    string                 `bson:"raw_body"`
+	Truncated  bool                   `bson:"truncated,omitempty"`
 	CreatedAt  time.Time              `bson:"created_at"`
 }
 
 type HTTPResponse struct {
-	Code     int               `bson:"code"`
-	Message  string            `bson:"message"`
-	Headers  map[string]string `bson:"headers"`
-	Body     string            `bson:"body"`
-	Duration time.Duration     `bson:"duration"`
+	Code      int               `bson:"code"`
+	Message   string            `bson:"message"`
+	Headers   map[string]string `bson:"headers"`
+	Body      string            `bson:"body"`
+	Truncated bool              `bson:"truncated,omitempty"`
+	Trailers  map[string]string `bson:"trailers,omitempty"`
+	Duration  time.Duration     `bson:"duration"`
+}
+
+type Metadata struct {
+	Timestamp time.Time `bson:"timestamp"`
+	ClientIP  string    `bson:"client_ip"`
+	// User — имя пользователя, прошедшего Proxy-Authorization.
+	User string `bson:"user,omitempty"`
+	SNI  string `bson:"sni,omitempty"`
+	// Protocol — протокол между клиентом и прокси, UpstreamProtocol — между прокси и целевым сервером.
+	Protocol         string       `bson:"protocol,omitempty"`
+	UpstreamProtocol string       `bson:"upstream_protocol,omitempty"`
+	UpstreamTLS      *UpstreamTLS `bson:"upstream_tls,omitempty"`
+	// Tunnel заполняется для соединений, переданных без расшифровки
+	Tunnel *Tunnel `bson:"tunnel,omitempty"`
+}
+
+// Tunnel — объём данных, переданных через туннель без расшифровки.
+type Tunnel struct {
+	BytesSent     int64 `bson:"bytes_sent"`
+	BytesReceived int64 `bson:"bytes_received"`
+	// Learned — хост переключён автоматически после неудачных рукопожатий
+	Learned bool `bson:"learned,omitempty"`
+	// Raw — поток из SOCKS5 или прозрачного режима не распознан как TLS или HTTP и передан как есть
+	Raw bool `bson:"raw,omitempty"`
+}
+
+// StreamEvent — событие text/event-stream, записанное в момент получения.
+type StreamEvent struct {
+	Timestamp time.Time `bson:"timestamp"`
+	ID        string    `bson:"id,omitempty"`
+	Event     string    `bson:"event,omitempty"`
+	Data      string    `bson:"data"`
+	Truncated bool      `bson:"truncated,omitempty"`
+}
+
+// ErrTruncatedMessage — сообщение WebSocket сохранено не полностью, поэтому его нельзя отправить повторно.
+var ErrTruncatedMessage = errors.New("websocket message was captured only partially")
+
+// WebSocketMessage — текстовое или бинарное сообщение WebSocket, перехваченное после апгрейда.
+type WebSocketMessage struct {
+	Timestamp time.Time `bson:"timestamp"`
+	// Direction: "client" — от клиента к серверу, "server" — от сервера к клиенту.
+	Direction string `bson:"direction"`
+	Opcode    int    `bson:"opcode"`
+	Payload   []byte `bson:"payload"`
+	Truncated bool   `bson:"truncated,omitempty"`
+	// Original — сообщение до применения правил подмены, если оно было изменено.
+	Original []byte `bson:"original,omitempty"`
+}
+
+func (m WebSocketMessage) Type() string {
+	switch m.Opcode {
+	case 0x1:
+		return "text"
+	case 0x2:
+		return "binary"
+	default:
+		return fmt.Sprintf("opcode %d", m.Opcode)
+	}
+}
+
+// Display возвращает текст сообщения, а бинарные данные — в base64.
+func (m WebSocketMessage) Display() string {
+	return m.display(m.Payload)
+}
+
+func (m WebSocketMessage) DisplayOriginal() string {
+	return m.display(m.Original)
+}
+
+func (m WebSocketMessage) display(payload []byte) string {
+	if m.Opcode == 0x1 {
+		return string(payload)
+	}
+	return base64.StdEncoding.EncodeToString(payload)
 }
 
 type RequestRecord struct {
 	ID       primitive.ObjectID `bson:"_id"`
 	Request  HTTPRequest        `bson:"request"`
 	Response HTTPResponse       `bson:"response"`
-	Metadata struct {
-		Timestamp time.Time `bson:"timestamp"`
-		ClientIP  string    `bson:"client_ip"`
-	} `bson:"metadata"`
+	Metadata Metadata           `bson:"metadata"`
+	Events   []StreamEvent      `bson:"events,omitempty"`
+	Messages []WebSocketMessage `bson:"messages,omitempty"`
+	// EventsTruncated и MessagesTruncated — часть событий или сообщений не сохранена: превышен предел записи
+	EventsTruncated   bool `bson:"events_truncated,omitempty"`
+	MessagesTruncated bool `bson:"messages_truncated,omitempty"`
 }
 
 func (r *HTTPRequest) ToHTTPRequest() (*http.Request, error) {
-	// Собираем URL с параметрами
+	// Собираем URL с параметрами. Старые записи не содержат схемы и адреса цели,
+	// для них используются https и заголовок Host
+	scheme := r.Scheme
+	if scheme == "" {
+		scheme = "https"
+	}
+	host := r.Host
+	if host == "" {
+		host = r.Headers["Host"]
+	}
+
 	u := &url.URL{
-		Scheme: "https",
+		Scheme: scheme,
 		Path:   r.Path,
-		Host:   r.Headers["Host"], // Добавляем Host в URL
+		Host:   host,
 	}
 
 	query := u.Query()
@@ -107,17 +204,18 @@ func ParseHTTPRequest(req *http.Request) *HTTPRequest {
 	// Парсинг cookies
 	cookies := parseCookies(req.Header.Get("Cookie"))
 
-	// Парсинг тела запроса
-	var postParams map[string]interface{}
-	var rawBody string
-	if req.Method == http.MethodPost || req.Method == http.MethodPut {
-		bodyBytes, _ := io.ReadAll(req.Body)
-		req.Body = io.NopCloser(bytes.NewBuffer(bodyBytes)) // Восстанавливаем тело
-		rawBody = string(bodyBytes)
-
-		if req.Header.Get("Content-Type") == "application/x-www-form-urlencoded" {
-			postParams = parseQuery(string(bodyBytes))
-		}
+	// Адрес цели: из absolute-form URI или CONNECT, иначе из Host
+	scheme := req.URL.Scheme
+	if scheme == "" {
+		scheme = "http"
+	}
+	host := req.URL.Host
+	if host == "" {
+		host = req.Host
+	}
+	hostname, port, err := net.SplitHostPort(host)
+	if err != nil {
+		hostname, port = host, DefaultPort(scheme)
 	}
 
 	headers := flattenHeaders(req.Header)
@@ -128,41 +226,83 @@ func ParseHTTPRequest(req *http.Request) *HTTPRequest {
 	}
 
 	return &HTTPRequest{
-		Method:     req.Method,
-		Path:       req.URL.Path,
-		GetParams:  queryParams,
-		Headers:    headers,
-		Cookies:    cookies,
-		PostParams: postParams,
-		RawBody:    rawBody,
-		CreatedAt:  time.Now(),
+		Method:    req.Method,
+		Scheme:    scheme,
+		Host:      net.JoinHostPort(hostname, port),
+		Port:      port,
+		Path:      req.URL.Path,
+		GetParams: queryParams,
+		Headers:   headers,
+		Cookies:   cookies,
+		CreatedAt: time.Now(),
 	}
 }
 
-func ParseHTTPResponse(resp *http.Response, duration time.Duration) *HTTPResponse {
-	var reader io.Reader = resp.Body
+// SetBody сохраняет тело запроса, перехваченное при передаче на целевой сервер.
+func (r *HTTPRequest) SetBody(capture *BodyCapture) {
+	bodyBytes := capture.Bytes()
+	r.RawBody = string(bodyBytes)
+	r.Truncated = capture.Truncated()
 
-	// Обработка gzip
-	if strings.Contains(resp.Header.Get("Content-Encoding"), "gzip") {
-		gzReader, err := gzip.NewReader(resp.Body)
-		if err == nil {
-			reader = gzReader
-			defer gzReader.Close()
-		}
+	if r.Headers["Content-Type"] == "application/x-www-form-urlencoded" && !r.Truncated {
+		r.PostParams = parseQuery(string(bodyBytes))
 	}
+}
 
-	bodyBytes, _ := io.ReadAll(reader)
-	resp.Body = io.NopCloser(bytes.NewBuffer(bodyBytes)) // Восстанавливаем тело
-
+func ParseHTTPResponse(resp *http.Response, duration time.Duration) *HTTPResponse {
 	return &HTTPResponse{
 		Code:     resp.StatusCode,
 		Message:  resp.Status,
 		Headers:  flattenHeaders(resp.Header),
-		Body:     string(bodyBytes),
 		Duration: duration,
 	}
 }
 
+// SetBody сохраняет тело ответа, перехваченное при передаче клиенту.
+func (r *HTTPResponse) SetBody(capture *BodyCapture) {
+	bodyBytes := capture.Bytes()
+	r.Truncated = capture.Truncated()
+
+	// Обработка gzip: у обрезанного тела распаковывается доступная часть
+	if strings.Contains(r.Headers["Content-Encoding"], "gzip") {
+		gzReader, err := gzip.NewReader(bytes.NewReader(bodyBytes))
+		if err == nil {
+			decoded, _ := io.ReadAll(gzReader)
+			gzReader.Close()
+			if len(decoded) > 0 {
+				bodyBytes = decoded
+			}
+		}
+	}
+
+	r.Body = string(bodyBytes)
+}
+
+// SetTrailers сохраняет трейлеры ответа; они доступны только после чтения всего тела.
+func (r *HTTPResponse) SetTrailers(trailers http.Header) {
+	if len(trailers) > 0 {
+		r.Trailers = flattenHeaders(trailers)
+	}
+}
+
+// IsStreaming сообщает, что ответ является потоком событий и должен записываться по мере поступления.
+func IsStreaming(resp *http.Response) bool {
+	return strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream")
+}
+
+// IsWebSocketUpgrade сообщает, что клиент запрашивает апгрейд соединения до WebSocket.
+func IsWebSocketUpgrade(req *http.Request) bool {
+	return strings.EqualFold(req.Header.Get("Upgrade"), "websocket") &&
+		strings.Contains(strings.ToLower(req.Header.Get("Connection")), "upgrade")
+}
+
+func DefaultPort(scheme string) string {
+	if scheme == "https" {
+		return "443"
+	}
+	return "80"
+}
+
 func parseQuery(query string) map[string]interface{} {
 	values, _ := url.ParseQuery(query)
 	result := make(map[string]interface{})
@@ -0,0 +1,145 @@
+package entity
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// UpstreamTLS — параметры TLS-соединения прокси с целевым сервером.
+type UpstreamTLS struct {
+	Version     string `bson:"version"`
+	CipherSuite string `bson:"cipher_suite"`
+	ALPN        string `bson:"alpn,omitempty"`
+	// Verified — цепочка проверена; false, если проверка отключена правилом TLS.
+	Verified bool          `bson:"verified"`
+	Chain    []Certificate `bson:"chain"`
+	// WeakProtocol — версия ниже TLS 1.2 или небезопасный набор шифров.
+	WeakProtocol bool `bson:"weak_protocol"`
+	// ExpiredCertificate — хотя бы один сертификат цепочки не действовал в момент запроса.
+	ExpiredCertificate bool `bson:"expired_certificate"`
+	// HandshakeError — причина, по которой рукопожатие с целевым сервером не удалось.
+	HandshakeError string `bson:"handshake_error,omitempty"`
+}
+
+// Certificate — сертификат из цепочки, предъявленной целевым сервером.
+type Certificate struct {
+	Subject     string    `bson:"subject"`
+	Issuer      string    `bson:"issuer"`
+	DNSNames    []string  `bson:"dns_names,omitempty"`
+	IPAddresses []string  `bson:"ip_addresses,omitempty"`
+	NotBefore   time.Time `bson:"not_before"`
+	NotAfter    time.Time `bson:"not_after"`
+	SHA1        string    `bson:"sha1"`
+	SHA256      string    `bson:"sha256"`
+}
+
+// Expired сообщает, что сертификат не действовал в момент t.
+func (c Certificate) Expired(t time.Time) bool {
+	return t.Before(c.NotBefore) || t.After(c.NotAfter)
+}
+
+// ParseTLSState сохраняет параметры соединения и цепочку сертификатов целевого сервера.
+// Для соединений без TLS возвращает nil.
+func ParseTLSState(state *tls.ConnectionState) *UpstreamTLS {
+	if state == nil {
+		return nil
+	}
+
+	result := &UpstreamTLS{
+		Version:      tls.VersionName(state.Version),
+		CipherSuite:  tls.CipherSuiteName(state.CipherSuite),
+		ALPN:         state.NegotiatedProtocol,
+		Verified:     len(state.VerifiedChains) > 0,
+		WeakProtocol: state.Version < tls.VersionTLS12 || isInsecureCipherSuite(state.CipherSuite),
+	}
+
+	result.Chain, result.ExpiredCertificate = parseChain(state.PeerCertificates)
+
+	return result
+}
+
+// ParseTLSError сохраняет причину неудачного рукопожатия с целевым сервером:
+// непроверенную цепочку при ошибке проверки сертификата или устаревшую версию
+// протокола. Для ошибок, не связанных с TLS, возвращает nil.
+func ParseTLSError(err error) *UpstreamTLS {
+	result := &UpstreamTLS{HandshakeError: err.Error()}
+
+	var verificationErr *tls.CertificateVerificationError
+	var opErr *net.OpError
+	switch {
+	case errors.As(err, &verificationErr):
+		result.Chain, result.ExpiredCertificate = parseChain(verificationErr.UnverifiedCertificates)
+	case errors.As(err, &opErr) && opErr.Op == "remote error" && opErr.Err != nil &&
+		opErr.Err.Error() == "tls: protocol version not supported":
+		// Сервер не поддерживает ни одну из предложенных версий
+		result.WeakProtocol = true
+	case strings.Contains(err.Error(), unsupportedVersionMessage):
+		// Сервер выбрал версию ниже разрешённой; её номер есть только в тексте ошибки
+		result.WeakProtocol = true
+		_, version, _ := strings.Cut(err.Error(), unsupportedVersionMessage)
+		if id, parseErr := strconv.ParseUint(strings.TrimSpace(version), 16, 16); parseErr == nil {
+			result.Version = tls.VersionName(uint16(id))
+		}
+	default:
+		return nil
+	}
+
+	return result
+}
+
+const unsupportedVersionMessage = "server selected unsupported protocol version"
+
+func parseChain(certs []*x509.Certificate) ([]Certificate, bool) {
+	var chain []Certificate
+	expired := false
+
+	now := time.Now()
+	for _, cert := range certs {
+		sha1Fingerprint := sha1.Sum(cert.Raw)
+		sha256Fingerprint := sha256.Sum256(cert.Raw)
+
+		certificate := Certificate{
+			Subject:   cert.Subject.String(),
+			Issuer:    cert.Issuer.String(),
+			DNSNames:  cert.DNSNames,
+			NotBefore: cert.NotBefore,
+			NotAfter:  cert.NotAfter,
+			SHA1:      hex.EncodeToString(sha1Fingerprint[:]),
+			SHA256:    hex.EncodeToString(sha256Fingerprint[:]),
+		}
+		for _, ip := range cert.IPAddresses {
+			certificate.IPAddresses = append(certificate.IPAddresses, ip.String())
+		}
+
+		if certificate.Expired(now) {
+			expired = true
+		}
+
+		chain = append(chain, certificate)
+	}
+
+	return chain, expired
+}
+
+func isInsecureCipherSuite(id uint16) bool {
+	for _, suite := range tls.InsecureCipherSuites() {
+		if suite.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// Filter — условия отбора записей истории; выбранные условия должны выполняться одновременно.
+type Filter struct {
+	WeakProtocol       bool
+	ExpiredCertificate bool
+}
@@ -5,7 +5,10 @@ import (
 )
 
 type Repository interface {
-	Save(req *requestEntity.HTTPRequest, resp *requestEntity.HTTPResponse, clientIP string) (string, error)
+	Save(req *requestEntity.HTTPRequest, resp *requestEntity.HTTPResponse, metadata requestEntity.Metadata) (string, error)
 	GetByID(id string) (*requestEntity.RequestRecord, error)
-	GetAll() ([]*requestEntity.RequestRecord, error)
+	GetAll(filter requestEntity.Filter) ([]*requestEntity.RequestRecord, error)
+	UpdateResponse(id string, resp *requestEntity.HTTPResponse) error
+	AppendEvents(id string, events []*requestEntity.StreamEvent, truncated bool) error
+	AppendMessages(id string, messages []*requestEntity.WebSocketMessage, truncated bool) error
 }
@@ -22,14 +22,12 @@ func NewRequestRepository(mongoClient *mongo.Client) request.Repository {
 	return &RequestRepository{mongoCollection: collection}
 }
 
-func (repository *RequestRepository) Save(req *requestEntity.HTTPRequest, resp *requestEntity.HTTPResponse, clientIP string) (string, error) {
+func (repository *RequestRepository) Save(req *requestEntity.HTTPRequest, resp *requestEntity.HTTPResponse, metadata requestEntity.Metadata) (string, error) {
+	metadata.Timestamp = time.Now()
 	record := bson.M{
 		"request":  req,
 		"response": resp,
-		"metadata": bson.M{
-			"timestamp": time.Now(),
-			"client_ip": clientIP,
-		},
+		"metadata": metadata,
 	}
 
 	result, err := repository.mongoCollection.InsertOne(context.Background(), record)
@@ -61,10 +59,18 @@ func (repository *RequestRepository) GetByID(id string) (*requestEntity.RequestR
 	return &record, nil
 }
 
-func (repository *RequestRepository) GetAll() ([]*requestEntity.RequestRecord, error) {
+func (repository *RequestRepository) GetAll(filter requestEntity.Filter) ([]*requestEntity.RequestRecord, error) {
 	var records []*requestEntity.RequestRecord
 
-	cursor, err := repository.mongoCollection.Find(context.Background(), bson.D{})
+	query := bson.M{}
+	if filter.WeakProtocol {
+		query["metadata.upstream_tls.weak_protocol"] = true
+	}
+	if filter.ExpiredCertificate {
+		query["metadata.upstream_tls.expired_certificate"] = true
+	}
+
+	cursor, err := repository.mongoCollection.Find(context.Background(), query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all requests: %v", err)
 	}
@@ -84,3 +90,57 @@ func (repository *RequestRepository) GetAll() ([]*requestEntity.RequestRecord, e
 
 	return records, nil
 }
+
+func (repository *RequestRepository) UpdateResponse(id string, resp *requestEntity.HTTPResponse) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to convert ID to ObjectID: %v", err)
+	}
+
+	_, err = repository.mongoCollection.UpdateByID(context.Background(), objectID, bson.M{"$set": bson.M{"response": resp}})
+	if err != nil {
+		return fmt.Errorf("failed to update response: %v", err)
+	}
+
+	return nil
+}
+
+// AppendEvents дописывает пачку событий одним запросом; truncated помечает, что часть событий отброшена.
+func (repository *RequestRepository) AppendEvents(id string, events []*requestEntity.StreamEvent, truncated bool) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to convert ID to ObjectID: %v", err)
+	}
+
+	_, err = repository.mongoCollection.UpdateByID(context.Background(), objectID, appendUpdate("events", events, len(events), truncated))
+	if err != nil {
+		return fmt.Errorf("failed to append events: %v", err)
+	}
+
+	return nil
+}
+
+func (repository *RequestRepository) AppendMessages(id string, messages []*requestEntity.WebSocketMessage, truncated bool) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to convert ID to ObjectID: %v", err)
+	}
+
+	_, err = repository.mongoCollection.UpdateByID(context.Background(), objectID, appendUpdate("messages", messages, len(messages), truncated))
+	if err != nil {
+		return fmt.Errorf("failed to append websocket messages: %v", err)
+	}
+
+	return nil
+}
+
+func appendUpdate(field string, items interface{}, count int, truncated bool) bson.M {
+	update := bson.M{}
+	if count > 0 {
+		update["$push"] = bson.M{field: bson.M{"$each": items}}
+	}
+	if truncated {
+		update["$set"] = bson.M{field + "_truncated": true}
+	}
+	return update
+}
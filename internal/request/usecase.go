@@ -5,9 +5,12 @@ import (
 )
 
 type Usecase interface {
-	Save(httpReq *requestEntity.HTTPRequest, httpResp *requestEntity.HTTPResponse, clientIP string) (string, error)
+	Save(httpReq *requestEntity.HTTPRequest, httpResp *requestEntity.HTTPResponse, metadata requestEntity.Metadata) (string, error)
 	GetByID(id string) (*requestEntity.RequestRecord, error)
-	GetAll() ([]*requestEntity.RequestRecord, error)
+	GetAll(filter requestEntity.Filter) ([]*requestEntity.RequestRecord, error)
+	UpdateResponse(id string, httpResp *requestEntity.HTTPResponse) error
+	AppendEvents(id string, events []*requestEntity.StreamEvent, truncated bool) error
+	AppendMessages(id string, messages []*requestEntity.WebSocketMessage, truncated bool) error
 	RepeatByID(id string) (string, error)
 	ScanByID(id string) ([]string, []string, error)
 }
@@ -2,6 +2,7 @@ package usecase
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"time"
 
@@ -12,20 +13,24 @@ import (
 
 type RequestUsecase struct {
 	requestRepository request.Repository
+	transport         http.RoundTripper
+	bodyCaptureLimit  int64
 }
 
-func NewRequestUsecase(requestRepo request.Repository) request.Usecase {
+func NewRequestUsecase(requestRepo request.Repository, transport http.RoundTripper, bodyCaptureLimit int64) request.Usecase {
 	return &RequestUsecase{
 		requestRepository: requestRepo,
+		transport:         transport,
+		bodyCaptureLimit:  bodyCaptureLimit,
 	}
 }
 
-func (usecase *RequestUsecase) Save(httpReq *requestEntity.HTTPRequest, httpResp *requestEntity.HTTPResponse, clientIP string) (string, error) {
+func (usecase *RequestUsecase) Save(httpReq *requestEntity.HTTPRequest, httpResp *requestEntity.HTTPResponse, metadata requestEntity.Metadata) (string, error) {
 	if httpReq == nil || httpResp == nil {
 		return "", fmt.Errorf("failed to save request: request or response is nil")
 	}
 
-	id, err := usecase.requestRepository.Save(httpReq, httpResp, clientIP)
+	id, err := usecase.requestRepository.Save(httpReq, httpResp, metadata)
 	if err != nil {
 		return "", fmt.Errorf("failed to save request: %v", err)
 	}
@@ -40,26 +45,52 @@ func (usecase *RequestUsecase) GetByID(id string) (*requestEntity.RequestRecord,
 	return record, nil
 }
 
-func (usecase *RequestUsecase) GetAll() ([]*requestEntity.RequestRecord, error) {
-	records, err := usecase.requestRepository.GetAll()
+func (usecase *RequestUsecase) GetAll(filter requestEntity.Filter) ([]*requestEntity.RequestRecord, error) {
+	records, err := usecase.requestRepository.GetAll(filter)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get all requests: %v", err)
 	}
 	return records, nil
 }
 
+func (usecase *RequestUsecase) UpdateResponse(id string, httpResp *requestEntity.HTTPResponse) error {
+	if err := usecase.requestRepository.UpdateResponse(id, httpResp); err != nil {
+		return fmt.Errorf("failed to update response of request %s: %v", id, err)
+	}
+	return nil
+}
+
+func (usecase *RequestUsecase) AppendEvents(id string, events []*requestEntity.StreamEvent, truncated bool) error {
+	if err := usecase.requestRepository.AppendEvents(id, events, truncated); err != nil {
+		return fmt.Errorf("failed to append events to request %s: %v", id, err)
+	}
+	return nil
+}
+
+func (usecase *RequestUsecase) AppendMessages(id string, messages []*requestEntity.WebSocketMessage, truncated bool) error {
+	if err := usecase.requestRepository.AppendMessages(id, messages, truncated); err != nil {
+		return fmt.Errorf("failed to append websocket messages to request %s: %v", id, err)
+	}
+	return nil
+}
+
 func (usecase *RequestUsecase) RepeatByID(id string) (string, error) {
 	originalRecord, err := usecase.GetByID(id)
 	if err != nil {
 		return "", fmt.Errorf("failed to get original request: %v", err)
 	}
 
+	// Обрезанное тело отправилось бы целевому серверу как полноценный запрос
+	if originalRecord.Request.Truncated {
+		return "", requestEntity.ErrTruncatedBody
+	}
+
 	httpReq, err := originalRecord.Request.ToHTTPRequest()
 	if err != nil {
 		return "", fmt.Errorf("failed to convert to HTTP request: %v", err)
 	}
 
-	client := &http.Client{}
+	client := &http.Client{Transport: usecase.transport}
 	resp, err := client.Do(httpReq)
 	if err != nil {
 		return "", fmt.Errorf("failed to execute request: %v", err)
@@ -68,6 +99,9 @@ func (usecase *RequestUsecase) RepeatByID(id string) (string, error) {
 
 	newHttpReq := &requestEntity.HTTPRequest{
 		Method:     originalRecord.Request.Method,
+		Scheme:     originalRecord.Request.Scheme,
+		Host:       originalRecord.Request.Host,
+		Port:       originalRecord.Request.Port,
 		Path:       originalRecord.Request.Path,
 		GetParams:  originalRecord.Request.GetParams,
 		Headers:    originalRecord.Request.Headers,
@@ -77,9 +111,18 @@ func (usecase *RequestUsecase) RepeatByID(id string) (string, error) {
 		CreatedAt:  time.Now(),
 	}
 
+	body, capture := requestEntity.CaptureBody(resp.Body, usecase.bodyCaptureLimit)
+	if _, err := io.Copy(io.Discard, body); err != nil {
+		return "", fmt.Errorf("failed to read response body: %v", err)
+	}
+
 	newHttpResp := requestEntity.ParseHTTPResponse(resp, 0)
+	newHttpResp.SetBody(capture)
 
-	newID, err := usecase.requestRepository.Save(newHttpReq, newHttpResp, "system")
+	newID, err := usecase.requestRepository.Save(newHttpReq, newHttpResp, requestEntity.Metadata{
+		ClientIP:    "system",
+		UpstreamTLS: requestEntity.ParseTLSState(resp.TLS),
+	})
 	if err != nil {
 		return "", fmt.Errorf("failed to save repeated request: %v", err)
 	}
@@ -93,12 +136,16 @@ func (usecase *RequestUsecase) ScanByID(id string) ([]string, []string, error) {
 		return []string{}, []string{}, fmt.Errorf("failed to get original request: %v", err)
 	}
 
+	if originalRecord.Request.Truncated {
+		return []string{}, []string{}, requestEntity.ErrTruncatedBody
+	}
+
 	httpReq, err := originalRecord.Request.ToHTTPRequest()
 	if err != nil {
 		return []string{}, []string{}, fmt.Errorf("failed to convert to HTTP request: %v", err)
 	}
 
-	scanner := scanner.NewScanner()
+	scanner := scanner.NewScanner(usecase.transport)
 	points := scanner.ScanRequest(httpReq)
 
 	var (
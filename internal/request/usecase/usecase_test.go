@@ -0,0 +1,93 @@
+package usecase
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/bocharovatd/mitm-proxy/internal/request"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+)
+
+// recordRepository отдаёт одну запись и запоминает сохранённые запросы.
+type recordRepository struct {
+	request.Repository
+	record *requestEntity.RequestRecord
+	saved  []*requestEntity.HTTPRequest
+}
+
+func (r *recordRepository) GetByID(string) (*requestEntity.RequestRecord, error) {
+	return r.record, nil
+}
+
+func (r *recordRepository) Save(req *requestEntity.HTTPRequest, _ *requestEntity.HTTPResponse, _ requestEntity.Metadata) (string, error) {
+	r.saved = append(r.saved, req)
+	return "repeated", nil
+}
+
+// sentBodies — RoundTripper, который запоминает отправленные тела и отвечает 200.
+type sentBodies []string
+
+func (s *sentBodies) RoundTrip(req *http.Request) (*http.Response, error) {
+	body, _ := io.ReadAll(req.Body)
+	*s = append(*s, string(body))
+	return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: http.NoBody, Header: http.Header{}, Request: req}, nil
+}
+
+func TestRepeatByID(t *testing.T) {
+	tests := []struct {
+		name      string
+		request   requestEntity.HTTPRequest
+		wantErr   error
+		wantSent  bool
+		wantSaved bool
+	}{
+		{
+			name:      "complete body",
+			request:   requestEntity.HTTPRequest{Method: http.MethodPost, Scheme: "http", Host: "example.com", Path: "/", RawBody: "a=1"},
+			wantSent:  true,
+			wantSaved: true,
+		},
+		{
+			name:    "truncated body",
+			request: requestEntity.HTTPRequest{Method: http.MethodPost, Scheme: "http", Host: "example.com", Path: "/", RawBody: "a=1", Truncated: true},
+			wantErr: requestEntity.ErrTruncatedBody,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repository := &recordRepository{record: &requestEntity.RequestRecord{Request: tt.request}}
+			var sent sentBodies
+			usecase := NewRequestUsecase(repository, &sent, 1024)
+
+			id, err := usecase.RepeatByID("original")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if (len(sent) > 0) != tt.wantSent || (len(repository.saved) > 0) != tt.wantSaved {
+				t.Fatalf("sent %d, saved %d requests", len(sent), len(repository.saved))
+			}
+			if tt.wantSent && (strings.Join(sent, "") != tt.request.RawBody || id != "repeated") {
+				t.Errorf("sent %q with id %q, want %q", sent, id, tt.request.RawBody)
+			}
+		})
+	}
+}
+
+func TestScanTruncatedBody(t *testing.T) {
+	repository := &recordRepository{record: &requestEntity.RequestRecord{Request: requestEntity.HTTPRequest{
+		Method: http.MethodPost, Scheme: "http", Host: "example.com", Path: "/", RawBody: "a=1", Truncated: true,
+	}}}
+	var sent sentBodies
+	usecase := NewRequestUsecase(repository, &sent, 1024)
+
+	if _, _, err := usecase.ScanByID("original"); !errors.Is(err, requestEntity.ErrTruncatedBody) {
+		t.Fatalf("error = %v, want %v", err, requestEntity.ErrTruncatedBody)
+	}
+	if len(sent) != 0 {
+		t.Errorf("scan sent %d requests, want none", len(sent))
+	}
+}
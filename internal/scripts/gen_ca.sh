@@ -3,5 +3,4 @@
 mkdir certs/
 
 openssl genrsa -out certs/ca.key 2048
-openssl req -new -x509 -days 3650 -key certs/ca.key -out certs/ca.crt -subj "/CN=proxy CA"
-openssl genrsa -out certs/cert.key 2048
\ No newline at end of file
+openssl req -new -x509 -days 3650 -key certs/ca.key -out certs/ca.crt -subj "/CN=proxy CA"
\ No newline at end of file
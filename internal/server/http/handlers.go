@@ -1,19 +1,80 @@
 package http
 
 import (
+	"expvar"
+	"fmt"
+	"log"
 	"net/http"
 
+	authHandlers "github.com/bocharovatd/mitm-proxy/internal/auth/delivery/http"
+	authRepository "github.com/bocharovatd/mitm-proxy/internal/auth/repository"
+	authUsecase "github.com/bocharovatd/mitm-proxy/internal/auth/usecase"
+	grpcHandlers "github.com/bocharovatd/mitm-proxy/internal/grpc/delivery/http"
+	grpcRepository "github.com/bocharovatd/mitm-proxy/internal/grpc/repository"
+	grpcUsecase "github.com/bocharovatd/mitm-proxy/internal/grpc/usecase"
+	passthroughHandlers "github.com/bocharovatd/mitm-proxy/internal/passthrough/delivery/http"
+	passthroughRepository "github.com/bocharovatd/mitm-proxy/internal/passthrough/repository"
+	passthroughUsecase "github.com/bocharovatd/mitm-proxy/internal/passthrough/usecase"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/upstream"
 	requestHandlers "github.com/bocharovatd/mitm-proxy/internal/request/delivery/http"
 	requestRepository "github.com/bocharovatd/mitm-proxy/internal/request/repository"
 	requestUsecase "github.com/bocharovatd/mitm-proxy/internal/request/usecase"
+	webSocketHandlers "github.com/bocharovatd/mitm-proxy/internal/websocket/delivery/http"
+	webSocketRepository "github.com/bocharovatd/mitm-proxy/internal/websocket/repository"
+	webSocketUsecase "github.com/bocharovatd/mitm-proxy/internal/websocket/usecase"
 )
 
-func (s *Server) MapHandlers() {
+func (s *Server) MapHandlers() error {
+	transport, err := upstream.New(s.cfg.Upstream)
+	if err != nil {
+		return fmt.Errorf("failed to create upstream transport: %w", err)
+	}
+
+	authRepo := authRepository.NewAuthRepository(s.mongoClient)
+	if err := authRepo.Migrate(); err != nil {
+		return fmt.Errorf("failed to migrate sessions: %w", err)
+	}
+	authUC := authUsecase.NewAuthUsecase(authRepo, s.cfg.Web)
+	if !authUC.Enabled() {
+		log.Println("Web UI authentication is disabled: set MITM_WEB_USERS or MITM_WEB_API_TOKEN")
+	}
+	authH := authHandlers.NewAuthHandlers(authUC)
+	s.MUX.Use(authH.Middleware)
+	s.MUX.Handle("/login", http.HandlerFunc(authH.LoginPage)).Methods("GET")
+	s.MUX.Handle("/login", http.HandlerFunc(authH.Login)).Methods("POST")
+	s.MUX.Handle("/logout", http.HandlerFunc(authH.Logout)).Methods("POST")
+
 	requestRepo := requestRepository.NewRequestRepository(s.mongoClient)
-	requestUC := requestUsecase.NewRequestUsecase(requestRepo)
-	requestH := requestHandlers.NewRequestHandlers(requestUC)
+	requestUC := requestUsecase.NewRequestUsecase(requestRepo, transport, s.cfg.Proxy.BodyCaptureLimit)
+	grpcRepo := grpcRepository.NewGRPCRepository(s.mongoClient)
+	grpcUC := grpcUsecase.NewGRPCUsecase(grpcRepo, s.cfg.Proxy.BodyCaptureLimit)
+	requestH := requestHandlers.NewRequestHandlers(requestUC, grpcUC)
 	s.MUX.Handle("/requests", http.HandlerFunc(requestH.GetAll)).Methods("GET")
 	s.MUX.Handle("/requests/{requestID:[0-9a-fA-F]{24}}", http.HandlerFunc(requestH.GetByID)).Methods("GET")
-	s.MUX.Handle("/repeat/{requestID:[0-9a-fA-F]{24}}", http.HandlerFunc(requestH.RepeatByID)).Methods("GET")
-	s.MUX.Handle("/scan/{requestID:[0-9a-fA-F]{24}}", http.HandlerFunc(requestH.ScanByID)).Methods("GET")
+	s.MUX.Handle("/repeat/{requestID:[0-9a-fA-F]{24}}", http.HandlerFunc(requestH.RepeatByID)).Methods("POST")
+	s.MUX.Handle("/scan/{requestID:[0-9a-fA-F]{24}}", http.HandlerFunc(requestH.ScanByID)).Methods("POST")
+
+	webSocketRepo := webSocketRepository.NewWebSocketRepository(s.mongoClient)
+	webSocketUC := webSocketUsecase.NewWebSocketUsecase(webSocketRepo, requestRepo, transport, s.cfg.WebSocket.ReplayTimeout)
+	webSocketH := webSocketHandlers.NewWebSocketHandlers(webSocketUC)
+	s.MUX.Handle("/ws/rules", http.HandlerFunc(webSocketH.GetRules)).Methods("GET")
+	s.MUX.Handle("/ws/rules", http.HandlerFunc(webSocketH.AddRule)).Methods("POST")
+	s.MUX.Handle("/ws/rules/{ruleID:[0-9a-fA-F]{24}}/delete", http.HandlerFunc(webSocketH.DeleteRule)).Methods("POST")
+	s.MUX.Handle("/ws/{requestID:[0-9a-fA-F]{24}}/replay", http.HandlerFunc(webSocketH.ReplayByID)).Methods("POST")
+	s.MUX.Handle("/ws/{requestID:[0-9a-fA-F]{24}}/scan", http.HandlerFunc(webSocketH.ScanByID)).Methods("POST")
+
+	grpcH := grpcHandlers.NewGRPCHandlers(grpcUC)
+	s.MUX.Handle("/grpc/descriptors", http.HandlerFunc(grpcH.GetDescriptors)).Methods("GET")
+	s.MUX.Handle("/grpc/descriptors", http.HandlerFunc(grpcH.AddDescriptor)).Methods("POST")
+	s.MUX.Handle("/grpc/descriptors/{descriptorID:[0-9a-fA-F]{24}}/delete", http.HandlerFunc(grpcH.DeleteDescriptor)).Methods("POST")
+
+	passthroughRepo := passthroughRepository.NewPassthroughRepository(s.mongoClient)
+	passthroughUC := passthroughUsecase.NewPassthroughUsecase(passthroughRepo, s.cfg.Proxy.PassthroughAfterFailures)
+	passthroughH := passthroughHandlers.NewPassthroughHandlers(passthroughUC)
+	s.MUX.Handle("/passthrough", http.HandlerFunc(passthroughH.GetHosts)).Methods("GET")
+	s.MUX.Handle("/passthrough/reset", http.HandlerFunc(passthroughH.ResetAll)).Methods("POST")
+	s.MUX.Handle("/passthrough/{host}/reset", http.HandlerFunc(passthroughH.Reset)).Methods("POST")
+
+	s.MUX.Handle("/debug/vars", expvar.Handler()).Methods("GET")
+	return nil
 }
@@ -12,6 +12,8 @@ import (
 	"go.mongodb.org/mongo-driver/mongo"
 
 	"github.com/gorilla/mux"
+
+	"github.com/bocharovatd/mitm-proxy/internal/config"
 )
 
 const (
@@ -21,14 +23,17 @@ const (
 type Server struct {
 	MUX         *mux.Router
 	mongoClient *mongo.Client
+	cfg         *config.Config
 }
 
-func New(mongoClient *mongo.Client) *Server {
-	return &Server{MUX: mux.NewRouter(), mongoClient: mongoClient}
+func New(mongoClient *mongo.Client, cfg *config.Config) *Server {
+	return &Server{MUX: mux.NewRouter(), mongoClient: mongoClient, cfg: cfg}
 }
 
 func (s *Server) Run() error {
-	s.MapHandlers()
+	if err := s.MapHandlers(); err != nil {
+		return err
+	}
 
 	server := &http.Server{
 		Addr:         ":8000",
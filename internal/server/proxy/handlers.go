@@ -1,18 +1,49 @@
 package proxy
 
 import (
+	"fmt"
+
+	passthroughRepository "github.com/bocharovatd/mitm-proxy/internal/passthrough/repository"
+	passthroughUsecase "github.com/bocharovatd/mitm-proxy/internal/passthrough/usecase"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/ca"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/certcache"
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/upstream"
 	proxyHandlers "github.com/bocharovatd/mitm-proxy/internal/proxy/delivery/proxy"
 	proxyRepository "github.com/bocharovatd/mitm-proxy/internal/proxy/repository"
 	proxyUsecase "github.com/bocharovatd/mitm-proxy/internal/proxy/usecase"
 	requestRepository "github.com/bocharovatd/mitm-proxy/internal/request/repository"
 	requestUsecase "github.com/bocharovatd/mitm-proxy/internal/request/usecase"
+	webSocketRepository "github.com/bocharovatd/mitm-proxy/internal/websocket/repository"
+	webSocketUsecase "github.com/bocharovatd/mitm-proxy/internal/websocket/usecase"
 )
 
-func (p *Proxy) MapHandlers() {
+func (p *Proxy) MapHandlers() error {
+	authority, err := ca.Load(p.cfg.Certificate.CACertPath, p.cfg.Certificate.CAKeyPath, p.cfg.Certificate.Validity, p.cfg.Certificate.KeyAlgorithm)
+	if err != nil {
+		return fmt.Errorf("failed to load CA: %w", err)
+	}
+
+	transport, err := upstream.New(p.cfg.Upstream)
+	if err != nil {
+		return fmt.Errorf("failed to create upstream transport: %w", err)
+	}
+
 	proxyRepo := proxyRepository.NewProxyRepository(p.mongoClient)
-	proxyUC := proxyUsecase.NewProxyUsecase(proxyRepo)
+	if err := proxyRepo.Migrate(); err != nil {
+		return fmt.Errorf("failed to migrate certificates: %w", err)
+	}
+
+	proxyUC := proxyUsecase.NewProxyUsecase(proxyRepo, authority, certcache.New(p.cfg.Certificate.CacheSize), p.cfg.Certificate.Wildcard)
 	requestRepo := requestRepository.NewRequestRepository(p.mongoClient)
-	requestUC := requestUsecase.NewRequestUsecase(requestRepo)
-	proxyH := proxyHandlers.NewProxyHandlers(proxyUC, requestUC)
+	requestUC := requestUsecase.NewRequestUsecase(requestRepo, transport, p.cfg.Proxy.BodyCaptureLimit)
+	webSocketRepo := webSocketRepository.NewWebSocketRepository(p.mongoClient)
+	webSocketUC := webSocketUsecase.NewWebSocketUsecase(webSocketRepo, requestRepo, transport, p.cfg.WebSocket.ReplayTimeout)
+	passthroughRepo := passthroughRepository.NewPassthroughRepository(p.mongoClient)
+	if err := passthroughRepo.Migrate(); err != nil {
+		return fmt.Errorf("failed to migrate passthrough hosts: %w", err)
+	}
+	passthroughUC := passthroughUsecase.NewPassthroughUsecase(passthroughRepo, p.cfg.Proxy.PassthroughAfterFailures)
+	proxyH := proxyHandlers.NewProxyHandlers(proxyUC, requestUC, webSocketUC, passthroughUC, transport, p.cfg.Proxy)
 	p.handlers = proxyH
+	return nil
 }
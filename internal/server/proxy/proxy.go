@@ -12,20 +12,24 @@ import (
 
 	"go.mongodb.org/mongo-driver/mongo"
 
+	"github.com/bocharovatd/mitm-proxy/internal/config"
 	"github.com/bocharovatd/mitm-proxy/internal/proxy"
 )
 
 type Proxy struct {
 	handlers    proxy.Handlers
 	mongoClient *mongo.Client
+	cfg         *config.Config
 }
 
-func New(mongoClient *mongo.Client) *Proxy {
-	return &Proxy{mongoClient: mongoClient}
+func New(mongoClient *mongo.Client, cfg *config.Config) *Proxy {
+	return &Proxy{mongoClient: mongoClient, cfg: cfg}
 }
 
 func (p *Proxy) Run() error {
-	p.MapHandlers()
+	if err := p.MapHandlers(); err != nil {
+		return err
+	}
 
 	listener, err := net.Listen("tcp", ":8080")
 	if err != nil {
@@ -35,6 +39,36 @@ func (p *Proxy) Run() error {
 
 	log.Println("MITM Proxy started on :8080")
 
+	listeners := []net.Listener{listener}
+	handlers := []func(net.Conn){p.handlers.HandleConnection}
+
+	// Дополнительные входы включаются, если задан адрес
+	entryPoints := []struct {
+		name    string
+		address string
+		handle  func(net.Conn)
+	}{
+		{"SOCKS5", p.cfg.Proxy.SOCKS.Address, p.handlers.HandleSOCKSConnection},
+		{"Transparent", p.cfg.Proxy.TransparentAddress, p.handlers.HandleTransparentConnection},
+		{"Reverse proxy", p.cfg.Proxy.Reverse.Address, p.handlers.HandleReverseConnection},
+	}
+
+	for _, entryPoint := range entryPoints {
+		if entryPoint.address == "" {
+			continue
+		}
+
+		l, err := net.Listen("tcp", entryPoint.address)
+		if err != nil {
+			return err
+		}
+		defer l.Close()
+
+		listeners = append(listeners, l)
+		handlers = append(handlers, entryPoint.handle)
+		log.Printf("%s listener started on %s", entryPoint.name, entryPoint.address)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
 
 	go func() {
@@ -42,32 +76,44 @@ func (p *Proxy) Run() error {
 		signal.Notify(exit, os.Interrupt, syscall.SIGTERM)
 		<-exit
 		cancel()
-		listener.Close()
+		for _, l := range listeners {
+			l.Close()
+		}
 	}()
 
 	var wg sync.WaitGroup
 
+	for i := 1; i < len(listeners); i++ {
+		wg.Add(1)
+		go func(l net.Listener, handle func(net.Conn)) {
+			defer wg.Done()
+			serve(ctx, l, handle, &wg)
+		}(listeners[i], handlers[i])
+	}
+
+	serve(ctx, listener, p.handlers.HandleConnection, &wg)
+
+	log.Println("Shutting down MITM proxy...")
+	wg.Wait()
+	return nil
+}
+
+// serve принимает соединения до остановки прокси и обрабатывает каждое в отдельной горутине.
+func serve(ctx context.Context, listener net.Listener, handle func(net.Conn), wg *sync.WaitGroup) {
 	for {
-		select {
-		case <-ctx.Done():
-			log.Println("Shutting down MITM proxy...")
-			wg.Wait()
-			return nil
-		default:
-			conn, err := listener.Accept()
-			if err != nil {
-				if errors.Is(err, net.ErrClosed) {
-					continue
-				}
-				log.Println("Accept error:", err)
-				continue
+		conn, err := listener.Accept()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+				return
 			}
-
-			wg.Add(1)
-			go func(c net.Conn) {
-				defer wg.Done()
-				p.handlers.HandleConnection(c)
-			}(conn)
+			log.Println("Accept error:", err)
+			continue
 		}
+
+		wg.Add(1)
+		go func(c net.Conn) {
+			defer wg.Done()
+			handle(c)
+		}(conn)
 	}
 }
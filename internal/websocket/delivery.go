@@ -0,0 +1,13 @@
+package websocket
+
+import (
+	"net/http"
+)
+
+type Handlers interface {
+	GetRules(w http.ResponseWriter, r *http.Request)
+	AddRule(w http.ResponseWriter, r *http.Request)
+	DeleteRule(w http.ResponseWriter, r *http.Request)
+	ReplayByID(w http.ResponseWriter, r *http.Request)
+	ScanByID(w http.ResponseWriter, r *http.Request)
+}
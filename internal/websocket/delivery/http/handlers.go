@@ -0,0 +1,138 @@
+package http
+
+import (
+	"errors"
+	"html/template"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/websession"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/websocket"
+	websocketEntity "github.com/bocharovatd/mitm-proxy/internal/websocket/entity"
+)
+
+type WebSocketHandlers struct {
+	usecase websocket.Usecase
+	tmpl    *template.Template
+}
+
+func NewWebSocketHandlers(webSocketUC websocket.Usecase) websocket.Handlers {
+	tmpl := template.Must(template.ParseGlob("templates/*.html"))
+	return &WebSocketHandlers{
+		usecase: webSocketUC,
+		tmpl:    tmpl,
+	}
+}
+
+func (handlers *WebSocketHandlers) GetRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := handlers.usecase.GetRules()
+	if err != nil {
+		log.Printf("Failed to get websocket rules: %v", err)
+		return
+	}
+
+	data := struct {
+		Title     string
+		Rules     []*websocketEntity.Rule
+		CSRFToken string
+	}{
+		Title:     "WebSocket Rules",
+		Rules:     rules,
+		CSRFToken: websession.CSRFToken(r),
+	}
+
+	if err := handlers.tmpl.ExecuteTemplate(w, "websocket_rules.html", data); err != nil {
+		log.Printf("Failed to render template: %v", err)
+		return
+	}
+}
+
+func (handlers *WebSocketHandlers) AddRule(w http.ResponseWriter, r *http.Request) {
+	rule := &websocketEntity.Rule{
+		Direction: r.FormValue("direction"),
+		Match:     r.FormValue("match"),
+		Replace:   r.FormValue("replace"),
+		Regex:     r.FormValue("regex") != "",
+	}
+
+	if _, err := handlers.usecase.AddRule(rule); err != nil {
+		log.Printf("Failed to add websocket rule: %v", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	http.Redirect(w, r, "/ws/rules", http.StatusSeeOther)
+}
+
+func (handlers *WebSocketHandlers) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["ruleID"]
+
+	if err := handlers.usecase.DeleteRule(id); err != nil {
+		log.Printf("Failed to delete websocket rule: %v", err)
+	}
+
+	http.Redirect(w, r, "/ws/rules", http.StatusSeeOther)
+}
+
+func (handlers *WebSocketHandlers) ReplayByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["requestID"]
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, "Invalid form", http.StatusBadRequest)
+		return
+	}
+
+	var messages []int
+	for _, value := range r.Form["message"] {
+		index, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, "Invalid message index", http.StatusBadRequest)
+			return
+		}
+		messages = append(messages, index)
+	}
+
+	newId, err := handlers.usecase.ReplayByID(id, messages)
+	if errors.Is(err, requestEntity.ErrTruncatedMessage) {
+		http.Error(w, "Message was captured only partially (see MITM_BODY_CAPTURE_LIMIT) and cannot be replayed", http.StatusConflict)
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to replay websocket messages: %v", err)
+		http.Redirect(w, r, "/requests/"+id, http.StatusSeeOther)
+		return
+	}
+
+	http.Redirect(w, r, "/requests/"+newId, http.StatusSeeOther)
+}
+
+func (handlers *WebSocketHandlers) ScanByID(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["requestID"]
+
+	result, details, err := handlers.usecase.ScanByID(id)
+	if err != nil {
+		log.Printf("Failed to scan websocket messages: %v", err)
+		http.Error(w, "Scan failed", http.StatusInternalServerError)
+		return
+	}
+
+	data := struct {
+		Results []string
+		Details []string
+	}{
+		Results: result,
+		Details: details,
+	}
+
+	if err := handlers.tmpl.ExecuteTemplate(w, "scan_result.html", data); err != nil {
+		log.Printf("failed to render template: %v", err)
+		return
+	}
+}
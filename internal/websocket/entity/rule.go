@@ -0,0 +1,65 @@
+package entity
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	DirectionClient = "client"
+	DirectionServer = "server"
+	DirectionBoth   = "both"
+)
+
+// Rule — правило подмены в сообщениях WebSocket, применяемое на лету.
+type Rule struct {
+	ID        primitive.ObjectID `bson:"_id,omitempty"`
+	Direction string             `bson:"direction"`
+	Match     string             `bson:"match"`
+	Replace   string             `bson:"replace"`
+	Regex     bool               `bson:"regex"`
+
+	re *regexp.Regexp
+}
+
+// Compile проверяет правило и подготавливает регулярное выражение.
+func (r *Rule) Compile() error {
+	switch r.Direction {
+	case DirectionClient, DirectionServer, DirectionBoth:
+	default:
+		return fmt.Errorf("unknown direction %q", r.Direction)
+	}
+
+	if r.Match == "" {
+		return fmt.Errorf("match must not be empty")
+	}
+
+	if r.Regex {
+		re, err := regexp.Compile(r.Match)
+		if err != nil {
+			return fmt.Errorf("invalid regular expression: %v", err)
+		}
+		r.re = re
+	}
+
+	return nil
+}
+
+// Apply применяет правило к сообщению, идущему в направлении direction.
+func (r *Rule) Apply(direction string, payload []byte) ([]byte, bool) {
+	if r.Direction != DirectionBoth && r.Direction != direction {
+		return payload, false
+	}
+
+	var result []byte
+	if r.re != nil {
+		result = r.re.ReplaceAll(payload, []byte(r.Replace))
+	} else {
+		result = bytes.ReplaceAll(payload, []byte(r.Match), []byte(r.Replace))
+	}
+
+	return result, !bytes.Equal(result, payload)
+}
@@ -0,0 +1,11 @@
+package websocket
+
+import (
+	websocketEntity "github.com/bocharovatd/mitm-proxy/internal/websocket/entity"
+)
+
+type Repository interface {
+	SaveRule(rule *websocketEntity.Rule) (string, error)
+	GetRules() ([]*websocketEntity.Rule, error)
+	DeleteRule(id string) error
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+
+	"github.com/bocharovatd/mitm-proxy/internal/websocket"
+	websocketEntity "github.com/bocharovatd/mitm-proxy/internal/websocket/entity"
+)
+
+type WebSocketRepository struct {
+	mongoCollection *mongo.Collection
+}
+
+func NewWebSocketRepository(mongoClient *mongo.Client) websocket.Repository {
+	collection := mongoClient.Database("MongoBD").Collection("websocket_rules")
+	return &WebSocketRepository{mongoCollection: collection}
+}
+
+func (repository *WebSocketRepository) SaveRule(rule *websocketEntity.Rule) (string, error) {
+	result, err := repository.mongoCollection.InsertOne(context.Background(), rule)
+	if err != nil {
+		return "", fmt.Errorf("failed to insert rule: %v", err)
+	}
+
+	if oid, ok := result.InsertedID.(primitive.ObjectID); ok {
+		return oid.Hex(), nil
+	}
+
+	return "", fmt.Errorf("failed to get inserted ID")
+}
+
+func (repository *WebSocketRepository) GetRules() ([]*websocketEntity.Rule, error) {
+	var rules []*websocketEntity.Rule
+
+	cursor, err := repository.mongoCollection.Find(context.Background(), bson.D{})
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules: %v", err)
+	}
+	defer cursor.Close(context.Background())
+
+	for cursor.Next(context.Background()) {
+		var rule websocketEntity.Rule
+		if err := cursor.Decode(&rule); err != nil {
+			return nil, fmt.Errorf("failed to decode rule: %v", err)
+		}
+		rules = append(rules, &rule)
+	}
+
+	if err := cursor.Err(); err != nil {
+		return nil, fmt.Errorf("cursor error while getting rules: %v", err)
+	}
+
+	return rules, nil
+}
+
+func (repository *WebSocketRepository) DeleteRule(id string) error {
+	objectID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return fmt.Errorf("failed to convert ID to ObjectID: %v", err)
+	}
+
+	if _, err := repository.mongoCollection.DeleteOne(context.Background(), bson.M{"_id": objectID}); err != nil {
+		return fmt.Errorf("failed to delete rule: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,13 @@
+package websocket
+
+import (
+	websocketEntity "github.com/bocharovatd/mitm-proxy/internal/websocket/entity"
+)
+
+type Usecase interface {
+	AddRule(rule *websocketEntity.Rule) (string, error)
+	GetRules() ([]*websocketEntity.Rule, error)
+	DeleteRule(id string) error
+	ReplayByID(id string, messages []int) (string, error)
+	ScanByID(id string) ([]string, []string, error)
+}
@@ -0,0 +1,271 @@
+package usecase
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/bocharovatd/mitm-proxy/internal/pkg/scanner"
+	wsframe "github.com/bocharovatd/mitm-proxy/internal/pkg/websocket"
+	"github.com/bocharovatd/mitm-proxy/internal/request"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+	"github.com/bocharovatd/mitm-proxy/internal/websocket"
+	websocketEntity "github.com/bocharovatd/mitm-proxy/internal/websocket/entity"
+)
+
+type WebSocketUsecase struct {
+	webSocketRepository websocket.Repository
+	requestRepository   request.Repository
+	transport           http.RoundTripper
+	replayTimeout       time.Duration
+}
+
+func NewWebSocketUsecase(webSocketRepo websocket.Repository, requestRepo request.Repository, transport http.RoundTripper, replayTimeout time.Duration) websocket.Usecase {
+	return &WebSocketUsecase{
+		webSocketRepository: webSocketRepo,
+		requestRepository:   requestRepo,
+		transport:           transport,
+		replayTimeout:       replayTimeout,
+	}
+}
+
+func (usecase *WebSocketUsecase) AddRule(rule *websocketEntity.Rule) (string, error) {
+	if err := rule.Compile(); err != nil {
+		return "", fmt.Errorf("invalid rule: %v", err)
+	}
+
+	id, err := usecase.webSocketRepository.SaveRule(rule)
+	if err != nil {
+		return "", fmt.Errorf("failed to save rule: %v", err)
+	}
+	return id, nil
+}
+
+// GetRules возвращает правила, готовые к применению. Некорректные правила пропускаются.
+func (usecase *WebSocketUsecase) GetRules() ([]*websocketEntity.Rule, error) {
+	rules, err := usecase.webSocketRepository.GetRules()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rules: %v", err)
+	}
+
+	compiled := make([]*websocketEntity.Rule, 0, len(rules))
+	for _, rule := range rules {
+		if err := rule.Compile(); err != nil {
+			log.Printf("Skipping websocket rule %s: %v", rule.ID.Hex(), err)
+			continue
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+func (usecase *WebSocketUsecase) DeleteRule(id string) error {
+	if err := usecase.webSocketRepository.DeleteRule(id); err != nil {
+		return fmt.Errorf("failed to delete rule %s: %v", id, err)
+	}
+	return nil
+}
+
+// ReplayByID открывает новое WebSocket-соединение с заголовками исходного рукопожатия
+// и отправляет выбранные сообщения клиента (индексы в RequestRecord.Messages).
+// Если индексы не заданы, отправляются все сообщения клиента.
+func (usecase *WebSocketUsecase) ReplayByID(id string, messages []int) (string, error) {
+	originalRecord, err := usecase.requestRepository.GetByID(id)
+	if err != nil {
+		return "", fmt.Errorf("failed to get original request: %v", err)
+	}
+
+	if len(messages) == 0 {
+		for i := range originalRecord.Messages {
+			if originalRecord.Messages[i].Direction == websocketEntity.DirectionClient {
+				messages = append(messages, i)
+			}
+		}
+	}
+
+	var payloads []*requestEntity.WebSocketMessage
+	for _, i := range messages {
+		if i < 0 || i >= len(originalRecord.Messages) || originalRecord.Messages[i].Direction != websocketEntity.DirectionClient {
+			return "", fmt.Errorf("message %d is not a client message", i)
+		}
+		// Обрезанное сообщение отправилось бы серверу как полноценное
+		if originalRecord.Messages[i].Truncated {
+			return "", fmt.Errorf("message %d: %w", i, requestEntity.ErrTruncatedMessage)
+		}
+		payloads = append(payloads, &originalRecord.Messages[i])
+	}
+
+	response, exchanged, err := usecase.exchange(originalRecord, payloads)
+	if err != nil {
+		return "", fmt.Errorf("failed to replay websocket messages: %v", err)
+	}
+
+	newHttpReq := originalRecord.Request
+	newHttpReq.CreatedAt = time.Now()
+	newHttpResp := requestEntity.ParseHTTPResponse(response, 0)
+
+	newID, err := usecase.requestRepository.Save(&newHttpReq, newHttpResp, requestEntity.Metadata{ClientIP: "system"})
+	if err != nil {
+		return "", fmt.Errorf("failed to save replayed request: %v", err)
+	}
+
+	saved := make([]*requestEntity.WebSocketMessage, len(exchanged))
+	for i := range exchanged {
+		saved[i] = &exchanged[i]
+	}
+	if err := usecase.requestRepository.AppendMessages(newID, saved, false); err != nil {
+		return "", fmt.Errorf("failed to save replayed messages: %v", err)
+	}
+
+	return newID, nil
+}
+
+// ScanByID проверяет строковые поля JSON в сообщениях клиента на command injection,
+// отправляя каждое изменённое сообщение в новом соединении.
+func (usecase *WebSocketUsecase) ScanByID(id string) ([]string, []string, error) {
+	originalRecord, err := usecase.requestRepository.GetByID(id)
+	if err != nil {
+		return []string{}, []string{}, fmt.Errorf("failed to get original request: %v", err)
+	}
+
+	scanner := scanner.NewScanner(usecase.transport)
+
+	var (
+		vulnerabilities []string
+		scanErrors      []string
+		checked         = make(map[string]bool)
+	)
+
+	for i, message := range originalRecord.Messages {
+		if message.Direction != websocketEntity.DirectionClient || message.Opcode != wsframe.OpText {
+			continue
+		}
+		if message.Truncated {
+			scanErrors = append(scanErrors, fmt.Sprintf("message %d: %v", i, requestEntity.ErrTruncatedMessage))
+			continue
+		}
+
+		for _, point := range scanner.ScanJSON(message.Payload) {
+			key := point.Type + ":" + point.Name
+			if checked[key] {
+				continue
+			}
+			checked[key] = true
+
+			send := func(payload []byte) ([]byte, error) {
+				_, exchanged, err := usecase.exchange(originalRecord, []*requestEntity.WebSocketMessage{
+					{Direction: websocketEntity.DirectionClient, Opcode: wsframe.OpText, Payload: payload},
+				})
+				if err != nil {
+					return nil, err
+				}
+
+				var received []byte
+				for _, m := range exchanged {
+					if m.Direction == websocketEntity.DirectionServer {
+						received = append(received, m.Payload...)
+					}
+				}
+				return received, nil
+			}
+
+			isVulnerable, errMsg := scanner.TestJSONInjection(point, message.Payload, send)
+
+			if errMsg != nil {
+				scanErrors = append(scanErrors, fmt.Sprintf("message %d %s '%s': %s",
+					i, point.Type, point.Name, errMsg))
+				continue
+			}
+
+			if isVulnerable {
+				vulnerabilities = append(vulnerabilities,
+					fmt.Sprintf("message %d %s '%s' is vulnerable to command injection",
+						i, point.Type, point.Name))
+			}
+		}
+	}
+
+	return vulnerabilities, scanErrors, nil
+}
+
+// exchange выполняет рукопожатие по сохранённому запросу, отправляет сообщения и
+// собирает ответы сервера, пришедшие до истечения replayTimeout после последней отправки.
+func (usecase *WebSocketUsecase) exchange(record *requestEntity.RequestRecord, messages []*requestEntity.WebSocketMessage) (*http.Response, []requestEntity.WebSocketMessage, error) {
+	httpReq, err := record.Request.ToHTTPRequest()
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to convert to HTTP request: %v", err)
+	}
+
+	key := make([]byte, 16)
+	rand.Read(key)
+	httpReq.Header.Set("Sec-WebSocket-Key", base64.StdEncoding.EncodeToString(key))
+	httpReq.Header.Del("Sec-WebSocket-Extensions")
+	httpReq.Header.Del("Proxy-Connection")
+
+	client := &http.Client{Transport: usecase.transport}
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to execute handshake: %v", err)
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("unexpected handshake status: %s", resp.Status)
+	}
+
+	conn, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		resp.Body.Close()
+		return nil, nil, fmt.Errorf("connection does not support upgrade")
+	}
+	defer conn.Close()
+
+	var (
+		mu        sync.Mutex
+		exchanged []requestEntity.WebSocketMessage
+	)
+
+	add := func(direction string, opcode byte, payload []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		exchanged = append(exchanged, requestEntity.WebSocketMessage{
+			Timestamp: time.Now(),
+			Direction: direction,
+			Opcode:    int(opcode),
+			Payload:   payload,
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		wsframe.Pipe(io.Discard, conn, false, false, wsframe.MaxMessagePayload, func(message *wsframe.Message) {
+			add(websocketEntity.DirectionServer, message.Opcode, message.Payload)
+		})
+	}()
+
+	for _, message := range messages {
+		opcode := byte(message.Opcode)
+		if err := wsframe.WriteMessage(conn, opcode, message.Payload, true); err != nil {
+			return nil, nil, fmt.Errorf("failed to send message: %v", err)
+		}
+		add(websocketEntity.DirectionClient, opcode, message.Payload)
+	}
+
+	select {
+	case <-done:
+	case <-time.After(usecase.replayTimeout):
+		wsframe.WriteMessage(conn, wsframe.OpClose, nil, true)
+		conn.Close()
+		<-done
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	return resp, exchanged, nil
+}
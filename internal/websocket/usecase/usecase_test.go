@@ -0,0 +1,58 @@
+package usecase
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	wsframe "github.com/bocharovatd/mitm-proxy/internal/pkg/websocket"
+	"github.com/bocharovatd/mitm-proxy/internal/request"
+	requestEntity "github.com/bocharovatd/mitm-proxy/internal/request/entity"
+	websocketEntity "github.com/bocharovatd/mitm-proxy/internal/websocket/entity"
+)
+
+type recordRepository struct {
+	request.Repository
+	record *requestEntity.RequestRecord
+}
+
+func (r recordRepository) GetByID(string) (*requestEntity.RequestRecord, error) {
+	return r.record, nil
+}
+
+// refusingTransport не даёт открыть соединение: до отправки дело доходить не должно.
+type refusingTransport struct{ t *testing.T }
+
+func (rt refusingTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	rt.t.Error("replay opened a connection")
+	return nil, errors.New("unexpected connection")
+}
+
+func TestReplayTruncatedMessage(t *testing.T) {
+	record := &requestEntity.RequestRecord{
+		Request: requestEntity.HTTPRequest{Method: http.MethodGet, Scheme: "http", Host: "example.com", Path: "/ws"},
+		Messages: []requestEntity.WebSocketMessage{
+			{Direction: websocketEntity.DirectionClient, Opcode: wsframe.OpText, Payload: []byte("complete")},
+			{Direction: websocketEntity.DirectionServer, Opcode: wsframe.OpText, Payload: []byte("reply")},
+			{Direction: websocketEntity.DirectionClient, Opcode: wsframe.OpText, Payload: []byte("cut"), Truncated: true},
+		},
+	}
+
+	tests := []struct {
+		name     string
+		messages []int
+	}{
+		{name: "all client messages"},
+		{name: "selected truncated message", messages: []int{2}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			usecase := NewWebSocketUsecase(nil, recordRepository{record: record}, refusingTransport{t}, time.Second)
+			if _, err := usecase.ReplayByID("original", tt.messages); !errors.Is(err, requestEntity.ErrTruncatedMessage) {
+				t.Errorf("error = %v, want %v", err, requestEntity.ErrTruncatedMessage)
+			}
+		})
+	}
+}